@@ -0,0 +1,146 @@
+package bzip2
+
+import (
+	"math"
+)
+
+const (
+	// statsWindow is the number of input bytes compared to
+	// the block by Writers with AdaptiveBlocks set.
+	statsWindow = 8 << 10
+	// minAdaptiveWindows is the number of windows in a block
+	// before it can end early.
+	minAdaptiveWindows = 4
+	// maxDivergence is the number of extra bits per byte a window
+	// can take when coded with the block's byte frequencies, before
+	// ending the block ahead of it is considered.
+	maxDivergence = 1.0
+	// adaptiveSample is the number of bytes at the end of a block
+	// compressed with a shifted window to estimate if ending the
+	// block ahead of it saves space.
+	adaptiveSample = 64 << 10
+)
+
+// count adds the bytes in p to the blocks byte frequencies,
+// keeping the last adaptiveSample bytes of the block.
+func (b *block) count(p []byte) {
+	for _, v := range p {
+		b.counts[v]++
+	}
+
+	if len(p) > adaptiveSample {
+		p = p[len(p)-adaptiveSample:]
+	}
+	if drop := len(b.recent) + len(p) - adaptiveSample; drop > 0 {
+		b.recent = b.recent[:copy(b.recent, b.recent[drop:])]
+	}
+	b.recent = append(b.recent, p...)
+}
+
+// splitSaves checks if ending the block ahead of window takes
+// fewer bits than adding window to it. The bits are estimated
+// by compressing the last bytes of the block with and without
+// the window, since the BWT and Huffman trees adapt to mixed
+// data better than the byte frequencies show.
+func (b *block) splitSaves(window []byte) bool {
+	// The block ends within the window anyway.
+	if b.Len()+len(window) >= b.size {
+		return false
+	}
+
+	continued := b.sampleBits(b.recent, window)
+	split := b.sampleBits(b.recent) + b.sampleBits(window)
+	return split < continued
+}
+
+// sampleBits returns the number of bits a block holding parts
+// compresses to.
+func (b *block) sampleBits(parts ...[]byte) int {
+	sample := newBlock(b.size, b.extreme, b.sorter)
+	for _, p := range parts {
+		sample.Write(p)
+	}
+
+	compressed := sample.Compress()
+	return len(compressed.data)*8 + int(compressed.n)
+}
+
+// shifted checks if the byte frequencies of window differ from the
+// blocks enough that the window may be better off in a new block,
+// which is then checked with splitSaves. It's the
+// Kullback-Leibler divergence of the window from the block, with
+// each frequency in the block incremented so unseen bytes are
+// expensive instead of impossible.
+func (b *block) shifted(window []byte) bool {
+	var counts [256]int
+	for _, v := range window {
+		counts[v]++
+	}
+
+	total := float64(b.dataLen + len(b.counts))
+	size := float64(len(window))
+
+	var divergence float64
+	for v, count := range counts {
+		if count == 0 {
+			continue
+		}
+
+		p := float64(count) / size
+		q := float64(b.counts[v]+1) / total
+		divergence += p * math.Log2(p/q)
+	}
+
+	return divergence > maxDivergence
+}
+
+// bufferWindow adds p to the window, writing each
+// complete window to the block as it's filled.
+func (w *Writer) bufferWindow(p []byte) (int, error) {
+	var n int
+	for n < len(p) {
+		nn := copy(w.window[len(w.window):cap(w.window)], p[n:])
+		w.window = w.window[:len(w.window)+nn]
+		n += nn
+
+		if len(w.window) == cap(w.window) {
+			err := w.writeWindow()
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// writeWindow writes the window to the block, first ending the
+// block if the window is complete, shifted from it, and ending the
+// block saves space. The bytes left when the block ends are kept
+// to begin the next window, so the windows always begin at the
+// same points relative to a block.
+func (w *Writer) writeWindow() error {
+	if len(w.window) == statsWindow && w.block.dataLen >= minAdaptiveWindows*statsWindow &&
+		w.block.shifted(w.window) && w.block.splitSaves(w.window) {
+		err := w.writeBlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := w.fill(w.window)
+	w.window = w.window[:copy(w.window, w.window[n:])]
+	return err
+}
+
+// flushWindow writes the bytes buffered in the window.
+func (w *Writer) flushWindow() error {
+	for len(w.window) > 0 {
+		err := w.writeWindow()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package bzip2
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+// adaptiveData returns text with binary data in the middle.
+func adaptiveData() []byte {
+	text := rsyncData()
+	binary := make([]byte, 200000)
+	rand.New(rand.NewSource(2)).Read(binary)
+
+	data := append([]byte(nil), text[:300000]...)
+	data = append(data, binary...)
+	return append(data, text[300000:600000]...)
+}
+
+// shiftingData returns text followed by random letters and then
+// random bytes, so the kinds of data don't repeat.
+func shiftingData() []byte {
+	rnd := rand.New(rand.NewSource(2))
+	letters := make([]byte, 200000)
+	for i := range letters {
+		letters[i] = byte('a' + rnd.Intn(26))
+	}
+	binary := make([]byte, 200000)
+	rnd.Read(binary)
+
+	data := append([]byte(nil), rsyncData()[:300000]...)
+	data = append(data, letters...)
+	return append(data, binary...)
+}
+
+// adaptiveWrite compresses data with adaptive blocks, writing
+// chunk bytes at a time. The output and index are returned.
+func adaptiveWrite(t *testing.T, data []byte, chunk int) ([]byte, *Index) {
+	var buf bytes.Buffer
+	writer, err := NewWriterOptions(&buf, &WriterOptions{
+		Level:          BestCompression,
+		AdaptiveBlocks: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p := data; len(p) > 0 && err == nil; {
+		n := chunk
+		if n > len(p) {
+			n = len(p)
+		}
+
+		_, err = writer.Write(p[:n])
+		p = p[n:]
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes(), writer.Index()
+}
+
+func TestWriterAdaptiveBlocks(t *testing.T) {
+	data := shiftingData()
+	out, index := adaptiveWrite(t, data, len(data))
+
+	// The data fits a single block, but should be split where it changes.
+	if len(index.Blocks) != 3 {
+		t.Fatal("Blocks should end when the data changes. Got", len(index.Blocks), "blocks")
+	}
+	for i, transition := range []int64{300000, 500000} {
+		offset := index.Blocks[i+1].DataOffset
+		if offset <= transition-statsWindow || offset >= transition+statsWindow {
+			t.Error("Block should begin within a window of", transition, "Got", offset)
+		}
+	}
+
+	decompressed, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("Output of the adaptive blocks is incorrect")
+	}
+}
+
+func TestWriterAdaptiveBlocksSize(t *testing.T) {
+	for _, data := range [][]byte{adaptiveData(), shiftingData()} {
+		var buf bytes.Buffer
+		writer, err := NewWriterLevel(&buf, BestCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = writer.Write(data)
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, _ := adaptiveWrite(t, data, len(data))
+		if len(out) > buf.Len() {
+			t.Error("Adaptive blocks shouldn't make the output larger. Got", len(out),
+				"wanted at most", buf.Len())
+		}
+	}
+}
+
+func TestWriterAdaptiveBlocksUniform(t *testing.T) {
+	_, index := adaptiveWrite(t, linesData(), 1000)
+	if len(index.Blocks) != 1 {
+		t.Error("Uniform data shouldn't be split. Got", len(index.Blocks), "blocks")
+	}
+}
+
+func TestWriterAdaptiveBlocksWrites(t *testing.T) {
+	data := shiftingData()
+
+	expected, _ := adaptiveWrite(t, data, len(data))
+	out, _ := adaptiveWrite(t, data, 999)
+	if !bytes.Equal(out, expected) {
+		t.Error("Block boundaries shouldn't depend on the size of writes")
+	}
+}
@@ -0,0 +1,287 @@
+package bzip2
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/larzconwell/bzip2/bits"
+	"github.com/larzconwell/bzip2/bwt"
+)
+
+const (
+	// trailerLen is the max number of bytes in the stream trailer,
+	// the final magic and crc followed by up to 7 bits of padding.
+	trailerLen = 11
+	// appendScanBlocks is the max number of blocks read back from
+	// the trailer to find the header of the last stream.
+	appendScanBlocks = 8
+	// maxBlockBytes is the max number of bytes read back from the
+	// end of a block to find the block magic it begins with.
+	maxBlockBytes = 2 * BestCompression * baseBlockSize
+)
+
+// NewAppendWriter returns a new Writer that continues the last
+// stream in the bzip2 data in rws. The stream trailer is found at
+// the end of the data, and new blocks are written from the bit it
+// begins at, continuing the combined crc stored in it. Close writes
+// a new trailer, so the data remains a single stream.
+//
+// The blocks of the last stream are read back from the trailer to
+// find its header, so the new blocks use its block size. If it isn't
+// found within a few blocks, the smallest block size the blocks read
+// fit in is used instead. The new data written is never shorter than
+// the trailer replaced, so rws doesn't have to be truncated.
+// The streams before the trailer are used as is without being
+// verified. Index returns nil since the uncompressed offset of the
+// new blocks isn't known, and StreamOffsets only includes the
+// streams appended.
+//
+// If rws is empty the Writer is equivalent to NewWriter.
+func NewAppendWriter(rws io.ReadWriteSeeker) (*Writer, error) {
+	size, err := rws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return NewWriter(rws), nil
+	}
+
+	_, err = rws.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	_, err = readFileHeader(bits.NewReader(io.LimitReader(rws, 4)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the trailer at the end of the data.
+	tailLen := int64(trailerLen)
+	if size-4 < tailLen {
+		tailLen = size - 4
+	}
+	_, err = rws.Seek(size-tailLen, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	tail := make([]byte, tailLen)
+	_, err = io.ReadFull(rws, tail)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, crc, err := findTrailer(tail)
+	if err != nil {
+		return nil, err
+	}
+
+	// Continue writing from the byte the trailer begins in,
+	// keeping the bits before the trailer.
+	leading := uint(offset % 8)
+	leadingBits := uint64(tail[offset/8] >> (8 - leading))
+	offset += (size - tailLen) * 8
+
+	blockSize, err := lastStreamBlockSize(rws, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = rws.Seek(offset/8, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	bw := bits.NewWriter(rws)
+	bw.WriteBits(leading, leadingBits)
+
+	return &Writer{
+		bw:          bw,
+		base:        offset / 8 * 8,
+		block:       newBlock(blockSize, false, bwt.SuffixArray{}),
+		crc:         crc,
+		wroteHeader: true,
+		continued:   true,
+	}, nil
+}
+
+// lastStreamBlockSize returns the block size of the last stream in
+// r, whose blocks end at the bit offset end. The blocks are read back
+// from end until the header of the stream is found before one. If it
+// isn't found within appendScanBlocks blocks, the smallest block size
+// the blocks read fit in is used, since a stream can have blocks
+// smaller than its header allows.
+func lastStreamBlockSize(r io.ReadSeeker, end int64) (int, error) {
+	tr := &tailReader{r: r, offset: (end + 7) / 8}
+	level := BestSpeed
+	for i := 0; i <= appendScanBlocks && level < BestCompression; i++ {
+		headerLevel, err := tr.headerBefore(end)
+		if err != nil {
+			return 0, err
+		}
+		if headerLevel > 0 {
+			return headerLevel * baseBlockSize, nil
+		}
+		if i == appendScanBlocks {
+			break
+		}
+
+		start, rleLen, err := tr.blockBefore(end)
+		if err != nil {
+			return 0, err
+		}
+		if start < 0 {
+			if i == 0 {
+				return 0, StructuralError("last block not found")
+			}
+			break
+		}
+
+		blockLevel := (rleLen + baseBlockSize - 1) / baseBlockSize
+		if blockLevel > level {
+			level = blockLevel
+		}
+		end = start
+	}
+
+	return level * baseBlockSize, nil
+}
+
+// tailReader reads the data in r back from the end, keeping
+// the data read beginning at the byte offset offset.
+type tailReader struct {
+	r      io.ReadSeeker
+	data   []byte
+	offset int64
+}
+
+// extend reads up to a chunk of data before the data already read,
+// without going before the byte offset limit. False is returned if
+// there's nothing to read.
+func (tr *tailReader) extend(limit int64) (bool, error) {
+	if limit < 0 {
+		limit = 0
+	}
+	n := tr.offset - limit
+	if n <= 0 {
+		return false, nil
+	}
+	if n > parallelChunkSize {
+		n = parallelChunkSize
+	}
+
+	_, err := tr.r.Seek(tr.offset-n, io.SeekStart)
+	if err != nil {
+		return false, err
+	}
+	chunk := make([]byte, n, n+int64(len(tr.data)))
+	_, err = io.ReadFull(tr.r, chunk)
+	if err != nil {
+		return false, err
+	}
+
+	tr.data = append(chunk, tr.data...)
+	tr.offset -= n
+	return true, nil
+}
+
+// headerBefore checks if a stream header ends at the bit offset end,
+// returning its level, or 0 if there isn't one. Other than the file
+// header, a stream header must follow the trailer of another stream.
+func (tr *tailReader) headerBefore(end int64) (int, error) {
+	if end%8 != 0 || end < 32 {
+		return 0, nil
+	}
+	header := end/8 - 4
+
+	for tr.offset > header-trailerLen && tr.offset > 0 {
+		_, err := tr.extend(header - trailerLen)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	data := tr.data[header-tr.offset:]
+	level := int(data[3]) - '0'
+	if data[0] != 'B' || data[1] != 'Z' || data[2] != 'h' ||
+		level < BestSpeed || level > BestCompression {
+		return 0, nil
+	}
+	if header == 0 {
+		return level, nil
+	}
+
+	_, _, err := findTrailer(tr.data[:header-tr.offset])
+	if err != nil {
+		return 0, nil
+	}
+	return level, nil
+}
+
+// blockBefore finds the block ending at the bit offset end, returning
+// the bit offset it begins at and the length of its RLE encoded data.
+// Block magics found inside the block are skipped since the block
+// decoded from them doesn't end at end. If the block isn't found the
+// offset returned is -1.
+func (tr *tailReader) blockBefore(end int64) (int64, int, error) {
+	limit := (end+7)/8 - maxBlockBytes
+
+	// Candidates beginning at or after tried have been checked.
+	tried := end
+	for {
+		offset := tr.offset * 8
+		scanLen := (tried - offset + magicLen + 7) / 8
+		if scanLen > int64(len(tr.data)) {
+			scanLen = int64(len(tr.data))
+		}
+
+		var candidates []int64
+		for from := int64(0); ; {
+			begin, magic, ok := scanMagic(tr.data[:scanLen], from)
+			if !ok || begin+offset >= tried {
+				break
+			}
+			if magic == blockMagic {
+				candidates = append(candidates, begin+offset)
+			}
+			from = begin + 1
+		}
+
+		// The block magic the block begins with is usually the last one.
+		for i := len(candidates) - 1; i >= 0; i-- {
+			br := bits.NewReaderAt(bytes.NewReader(tr.data), candidates[i]-offset+magicLen)
+			block, err := readMTFBlock(br, BestCompression*baseBlockSize)
+			if err == nil && br.Offset()+offset == end {
+				return candidates[i], len(block.data), nil
+			}
+		}
+		tried = offset
+
+		ok, err := tr.extend(limit)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !ok {
+			return -1, 0, nil
+		}
+	}
+}
+
+// findTrailer finds the stream trailer at the end of data,
+// returning the bit offset it begins at and the combined
+// crc it contains.
+func findTrailer(data []byte) (int64, uint32, error) {
+	for padding := int64(0); padding < 8; padding++ {
+		offset := int64(len(data))*8 - padding - magicLen - 32
+		if offset < 0 {
+			break
+		}
+
+		br := bits.NewReaderAt(bytes.NewReader(data), offset)
+		magic := br.ReadBits(magicLen)
+		crc := uint32(br.ReadBits(32))
+		if magic == finalMagic && br.ReadBits(uint(padding)) == 0 {
+			return offset, crc, nil
+		}
+	}
+
+	return 0, 0, StructuralError("stream trailer not found")
+}
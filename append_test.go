@@ -0,0 +1,252 @@
+package bzip2
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+// memFile is an in memory io.ReadWriteSeeker, counting the bytes read.
+type memFile struct {
+	data   []byte
+	offset int64
+	read   int
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.offset >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.data[f.offset:])
+	f.offset += int64(n)
+	f.read += n
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if end := f.offset + int64(len(p)); end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+
+	n := copy(f.data[f.offset:], p)
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+// appendData appends p to the data in f with an append writer.
+func appendData(t *testing.T, f *memFile, p []byte) {
+	writer, err := NewAppendWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = writer.Write(p)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendWriter(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &memFile{data: compressed}
+	appendData(t, f, []byte("banana"))
+	appendData(t, f, []byte("apple"))
+
+	report, err := Verify(bytes.NewReader(f.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Streams != 1 || len(report.Blocks) != 5 {
+		t.Error("Appended data should be a single stream. Got", report.Streams,
+			"streams and", len(report.Blocks), "blocks")
+	}
+
+	out, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(f.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, append(linesData(), "bananaapple"...)) {
+		t.Error("Output of the appended data is incorrect")
+	}
+}
+
+func TestAppendWriterEmpty(t *testing.T) {
+	f := &memFile{}
+	appendData(t, f, []byte("banana"))
+	appendData(t, f, nil)
+
+	out, err := ioutil.ReadAll(NewReader(bytes.NewReader(f.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "banana" {
+		t.Error("Output is incorrect. Got", string(out), "wanted banana")
+	}
+}
+
+func TestAppendWriterIndex(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := NewAppendWriter(&memFile{data: compressed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write([]byte("banana"))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The uncompressed offset of the appended blocks isn't known.
+	if writer.Index() != nil {
+		t.Error("Appending shouldn't give an index. Got", writer.Index())
+	}
+}
+
+func TestAppendWriterMultistream(t *testing.T) {
+	// A level 9 stream followed by a level 1 stream.
+	var buf bytes.Buffer
+	for _, level := range []int{BestCompression, BestSpeed} {
+		writer, err := NewWriterLevel(&buf, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = writer.Write(linesData())
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The appended blocks have to fit the level 1 stream.
+	f := &memFile{data: buf.Bytes()}
+	appended := append(linesData(), linesData()...)
+	appendData(t, f, appended)
+
+	_, err := Verify(bytes.NewReader(f.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadAll(NewReader(bytes.NewReader(f.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := append(append(linesData(), linesData()...), appended...)
+	if !bytes.Equal(out, expected) {
+		t.Error("Output of the appended streams is incorrect")
+	}
+}
+
+func TestAppendWriterBlockSize(t *testing.T) {
+	tests := []struct {
+		level  int
+		blocks int
+	}{
+		{level: 3, blocks: 2},
+		// More blocks than are read back to find the header.
+		{level: 2, blocks: appendScanBlocks + 1},
+	}
+
+	// Large level 9 streams to go before the stream appended to.
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]byte, parallelChunkSize)
+	rnd.Read(data)
+	var first bytes.Buffer
+	writer, err := NewWriterLevel(&first, BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write(data)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(bytes.Repeat(first.Bytes(), 3))
+		data := make([]byte, test.blocks*test.level*baseBlockSize)
+		rnd.Read(data)
+
+		writer, err := NewWriterLevel(buf, test.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = writer.Write(data)
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := &memFile{data: buf.Bytes()}
+		writer, err = NewAppendWriter(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if writer.block.size != test.level*baseBlockSize {
+			t.Error("Block size is incorrect. Got", writer.block.size,
+				"wanted", test.level*baseBlockSize)
+		}
+
+		// Only the end of the data should be read.
+		if f.read > len(f.data)/2 {
+			t.Error("Too much data was read. Got", f.read, "of", len(f.data))
+		}
+	}
+}
+
+func TestAppendWriterInvalid(t *testing.T) {
+	_, err := NewAppendWriter(&memFile{data: []byte("banana and apple")})
+	if err != ErrHeader {
+		t.Error("Data that isn't bzip2 should return ErrHeader. Got", err)
+	}
+
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewAppendWriter(&memFile{data: compressed[:len(compressed)-4]})
+	if _, ok := err.(StructuralError); !ok {
+		t.Error("Data without a trailer should return a StructuralError. Got", err)
+	}
+}
@@ -0,0 +1,4 @@
+// Package bits provides access to read and write values bit-by-bit,
+// keeping track of the bit offset so values like the blocks in a
+// bzip2 stream can be located.
+package bits
@@ -0,0 +1,116 @@
+package bits
+
+import (
+	"bufio"
+	"io"
+	"math"
+)
+
+// Reader wraps an io.Reader and provides the ability to read
+// values bit-by-bit from it. Like Writer it's Read* methods don't
+// return the usual error, instead any error is kept and can be
+// checked afterwards.
+type Reader struct {
+	r       io.ByteReader
+	bits    uint64
+	n       uint
+	offset  int64
+	readErr error
+	err     error
+}
+
+// NewReader creates a bit reader reading from r. If r isn't
+// an io.ByteReader it's wrapped in a bufio.Reader.
+func NewReader(r io.Reader) *Reader {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return &Reader{r: br}
+}
+
+// NewReaderAt creates a bit reader reading from r beginning at
+// the bit offset given. Offset reports the bit offset from the
+// beginning of r, rather than the number of bits read.
+func NewReaderAt(r io.ReaderAt, offset int64) *Reader {
+	section := io.NewSectionReader(r, offset/8, math.MaxInt64-offset/8)
+	br := &Reader{r: bufio.NewReader(section), offset: offset / 8 * 8}
+	br.ReadBits(uint(offset % 8))
+
+	return br
+}
+
+// ReadBits reads n bits from the reader, n must be 56 or less.
+// If an error occurs 0 is returned and the error is kept.
+func (r *Reader) ReadBits(n uint) uint64 {
+	if !r.fill(n) {
+		r.err = r.readErr
+		return 0
+	}
+
+	r.offset += int64(n)
+	r.n -= n
+	return (r.bits >> r.n) & (1<<n - 1)
+}
+
+// PeekBits gets the next n bits without reading them, n must
+// be 56 or less. If fewer than n bits can be buffered 0 is
+// returned, Buffered giving the number of bits that can still be
+// read. The error isn't kept until a read needs the missing bits.
+func (r *Reader) PeekBits(n uint) uint64 {
+	if !r.fill(n) {
+		return 0
+	}
+
+	return (r.bits >> (r.n - n)) & (1<<n - 1)
+}
+
+// fill buffers at least n bits, returning false if it can't.
+// The error from the underlying reader is kept in readErr.
+func (r *Reader) fill(n uint) bool {
+	for r.n < n {
+		if r.readErr != nil {
+			return false
+		}
+
+		var b byte
+		b, r.readErr = r.r.ReadByte()
+		if r.readErr != nil {
+			return false
+		}
+
+		r.bits = (r.bits << 8) | uint64(b)
+		r.n += 8
+	}
+
+	return true
+}
+
+// ReadBit reads a single bit from the reader, returning
+// true if it's set.
+func (r *Reader) ReadBit() bool {
+	return r.ReadBits(1) == 1
+}
+
+// Align discards the buffered bits before the next byte,
+// so reading continues from the next byte.
+func (r *Reader) Align() {
+	r.ReadBits(r.n % 8)
+}
+
+// Offset gets the number of bits read, which is the bit
+// position in the underlying reader.
+func (r Reader) Offset() int64 {
+	return r.offset
+}
+
+// Buffered gets the number of buffered bits.
+func (r Reader) Buffered() uint {
+	return r.n
+}
+
+// Err gets the error for the bit reader.
+func (r Reader) Err() error {
+	return r.err
+}
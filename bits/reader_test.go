@@ -0,0 +1,128 @@
+package bits
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestReadBits(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{'\xbd', '\xb5', '\xd2', '\xb6', '\x50'}))
+
+	expected := []struct {
+		n    uint
+		bits uint64
+	}{
+		{4, 11}, {4, 13}, {5, 22}, {7, 93}, {4, 2}, {11, 1458}, {5, 16},
+	}
+	for _, e := range expected {
+		actual := r.ReadBits(e.n)
+		if actual != e.bits {
+			t.Error("Bits read don't match expected value. Got", actual,
+				"wanted", e.bits)
+		}
+	}
+
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+
+	r.ReadBits(8)
+	if r.Err() != io.EOF {
+		t.Error("Reading past the end should return io.EOF. Got", r.Err())
+	}
+}
+
+func TestReadBit(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{'\xa0'}))
+
+	expected := []bool{true, false, true, false, false}
+	for _, e := range expected {
+		if r.ReadBit() != e {
+			t.Error("Bit read doesn't match expected value")
+		}
+	}
+
+	if r.Buffered() != 3 {
+		t.Error("Buffered bits incorrect. Got", r.Buffered(), "wanted 3")
+	}
+}
+
+func TestReaderAlign(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{'\xa0', '\x5f'}))
+
+	r.ReadBits(3)
+	if r.Offset() != 3 {
+		t.Error("Offset incorrect. Got", r.Offset(), "wanted 3")
+	}
+
+	r.Align()
+	if r.Offset() != 8 {
+		t.Error("Offset incorrect after aligning. Got", r.Offset(), "wanted 8")
+	}
+
+	if r.ReadBits(8) != 0x5f {
+		t.Error("Reading after aligning should read the next byte")
+	}
+}
+
+func TestReaderAlignAfterPeek(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{'\xa0', '\x5f'}))
+
+	r.ReadBits(3)
+	r.PeekBits(10)
+	r.Align()
+	if r.Offset() != 8 {
+		t.Error("Offset incorrect after aligning. Got", r.Offset(), "wanted 8")
+	}
+
+	if r.ReadBits(8) != 0x5f {
+		t.Error("Reading after aligning should read the next byte")
+	}
+}
+
+func TestPeekBits(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{'\xbd', '\xb5'}))
+
+	r.ReadBits(4)
+	if r.PeekBits(8) != 0xdb {
+		t.Error("Peeked bits incorrect. Got", r.PeekBits(8), "wanted", 0xdb)
+	}
+	if r.Offset() != 4 {
+		t.Error("Peeking shouldn't change the offset. Got", r.Offset())
+	}
+	if r.ReadBits(4) != 13 {
+		t.Error("Reading after peeking should read the peeked bits")
+	}
+
+	if r.PeekBits(16) != 0 || r.Buffered() != 8 {
+		t.Error("Peeking past the end should return 0. Got", r.Buffered(), "buffered bits")
+	}
+	if r.ReadBits(8) != 0xb5 || r.Err() != nil {
+		t.Error("Buffered bits should be readable after peeking past the end. Got", r.Err())
+	}
+
+	if r.ReadBits(1) != 0 || r.Err() != io.EOF {
+		t.Error("Reading past the end should return io.EOF. Got", r.Err())
+	}
+}
+
+func TestNewReaderAt(t *testing.T) {
+	data := []byte{'\xbd', '\xb5', '\xd2', '\xb6', '\x50'}
+
+	r := NewReaderAt(bytes.NewReader(data), 13)
+	if r.Offset() != 13 {
+		t.Error("Offset incorrect. Got", r.Offset(), "wanted 13")
+	}
+	if r.ReadBits(7) != 93 {
+		t.Error("Bits read don't match expected value")
+	}
+	if r.Offset() != 20 {
+		t.Error("Offset incorrect. Got", r.Offset(), "wanted 20")
+	}
+
+	r.Align()
+	if r.Offset() != 24 || r.ReadBits(8) != 0xb6 {
+		t.Error("Reading after aligning should read the next byte")
+	}
+}
@@ -0,0 +1,161 @@
+package bits
+
+import (
+	"io"
+)
+
+// bufferSize is the number of bytes buffered before
+// they're written to the underlying io.Writer.
+const bufferSize = 4096
+
+// Writer wraps an io.Writer and provides the ability to write
+// values bit-by-bit to it. It's Write* methods don't return the
+// usual error because error handling is verbose. Instead, any
+// error is kept and can be checked afterwards.
+//
+// Bits are collected in a 64-bit register and moved into a byte
+// buffer in bulk, the underlying io.Writer is only written to when
+// the buffer fills or on Flush and Close.
+type Writer struct {
+	w      io.Writer
+	buf    []byte
+	bits   uint64
+	n      uint
+	offset int64
+	err    error
+}
+
+// NewWriter creates a bit writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, buf: make([]byte, 0, bufferSize)}
+}
+
+// WriteBits writes n bits to the writer, n must be 56 or less.
+// The bits are buffered until the buffer fills or Flush is called.
+func (w *Writer) WriteBits(n uint, bits uint64) {
+	if w.err != nil {
+		return
+	}
+
+	// Make room in the register, it only holds a partial byte after.
+	if w.n+n > 64 {
+		w.drain()
+	}
+
+	w.bits = (w.bits << n) | (bits & (1<<n - 1))
+	w.n += n
+	w.offset += int64(n)
+}
+
+// WriteBytes writes the bytes in p to the writer, p doesn't
+// have to be aligned with the bits already written.
+func (w *Writer) WriteBytes(p []byte) {
+	if w.err != nil {
+		return
+	}
+	w.offset += int64(len(p)) * 8
+	w.drain()
+
+	// Not aligned, every byte has to be shifted into place.
+	if w.n != 0 {
+		for _, b := range p {
+			if w.n > 56 {
+				w.drain()
+			}
+
+			w.bits = (w.bits << 8) | uint64(b)
+			w.n += 8
+		}
+		return
+	}
+
+	if len(w.buf)+len(p) > bufferSize {
+		w.write()
+		if w.err != nil {
+			return
+		}
+
+		// Too large to buffer, write it directly.
+		if len(p) >= bufferSize {
+			_, w.err = w.w.Write(p)
+			return
+		}
+	}
+	w.buf = append(w.buf, p...)
+}
+
+// Align pads the buffered bits with zeros, so writing
+// continues from the next byte.
+func (w *Writer) Align() {
+	if w.n%8 != 0 {
+		w.WriteBits(8-w.n%8, 0)
+	}
+}
+
+// Flush writes the buffered bytes to the underlying io.Writer,
+// bits that don't make up a full byte stay buffered.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.drain()
+	w.write()
+	return w.err
+}
+
+// Close aligns the writer and flushes all the buffered bits
+// to the underlying io.Writer, but does not close the
+// underlying io.Writer.
+func (w *Writer) Close() error {
+	w.Align()
+	return w.Flush()
+}
+
+// Offset gets the number of bits written, including
+// the buffered bits. It can be used to record where
+// values begin in the output.
+func (w Writer) Offset() int64 {
+	return w.offset
+}
+
+// Buffered gets the number of buffered bits that
+// don't make up a full byte.
+func (w Writer) Buffered() uint {
+	return w.n % 8
+}
+
+// BufferedBits gets the value of the buffered bits
+// that don't make up a full byte.
+func (w Writer) BufferedBits() uint64 {
+	return w.bits & (1<<(w.n%8) - 1)
+}
+
+// Err gets the error for the bit writer.
+func (w Writer) Err() error {
+	return w.err
+}
+
+// drain moves the full bytes in the register to the buffer,
+// writing the buffer if it's full.
+func (w *Writer) drain() {
+	for w.n >= 8 {
+		w.n -= 8
+		w.buf = append(w.buf, byte(w.bits>>w.n))
+	}
+	w.bits &= 1<<w.n - 1
+
+	if len(w.buf) >= bufferSize {
+		w.write()
+	}
+}
+
+// write writes the buffer to the underlying io.Writer.
+func (w *Writer) write() {
+	if w.err != nil || len(w.buf) == 0 {
+		return
+	}
+
+	_, w.err = w.w.Write(w.buf)
+	w.buf = w.buf[:0]
+}
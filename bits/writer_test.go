@@ -0,0 +1,131 @@
+package bits
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWriteBits(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	w.WriteBits(4, 11)
+	w.WriteBits(4, 13)
+	w.WriteBits(5, 22)
+	w.WriteBits(7, 93)
+	w.WriteBits(4, 2)
+	w.WriteBits(11, 1458)
+	w.WriteBits(5, 16)
+	if buf.Len() != 0 {
+		t.Error("Bytes shouldn't be written until flushed. Got", buf.Len())
+	}
+
+	err := w.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{'\xbd', '\xb5', '\xd2', '\xb6', '\x50'}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Error("Bytes don't match expected value. Got", buf.Bytes(), "wanted", expected)
+	}
+}
+
+func TestWriteBytes(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	w.WriteBytes([]byte{'\xbd'})
+	w.WriteBits(4, 11)
+	w.WriteBytes([]byte{'\xdb', '\x5d'})
+	err := w.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Buffered() != 4 || w.BufferedBits() != 13 {
+		t.Error("Buffered bits incorrect. Got", w.Buffered(), w.BufferedBits(),
+			"wanted 4 13")
+	}
+
+	expected := []byte{'\xbd', '\xbd', '\xb5'}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Error("Bytes don't match expected value. Got", buf.Bytes(), "wanted", expected)
+	}
+}
+
+func TestWriterBufferFill(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	for i := 0; i < bufferSize; i++ {
+		w.WriteBits(8, uint64(i))
+	}
+	w.WriteBits(56, 0)
+	if buf.Len() != bufferSize {
+		t.Error("A full buffer should have been written. Got", buf.Len())
+	}
+
+	large := make([]byte, bufferSize*2)
+	w.WriteBytes(large)
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != bufferSize*3+7 {
+		t.Error("All bytes should have been written. Got", buf.Len())
+	}
+}
+
+func TestWriterClose(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	w.WriteBits(3, 5)
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != 1 || buf.Bytes()[0] != '\xa0' {
+		t.Error("Close should pad the last byte with zeros. Got", buf.Bytes())
+	}
+	if w.Offset() != 8 {
+		t.Error("Offset incorrect. Got", w.Offset(), "wanted 8")
+	}
+}
+
+func TestWriterError(t *testing.T) {
+	w := NewWriter(errWriter{})
+
+	w.WriteBits(8, 1)
+	err := w.Flush()
+	if err != io.ErrShortWrite || w.Err() != io.ErrShortWrite {
+		t.Error("Write error should be kept. Got", err)
+	}
+
+	w.WriteBits(8, 1)
+	if w.Close() != io.ErrShortWrite {
+		t.Error("Close should return the kept error")
+	}
+}
+
+// errWriter is an io.Writer that always fails.
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, io.ErrShortWrite
+}
+
+func TestWriterOffset(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	w.WriteBits(3, 5)
+	w.WriteBytes([]byte{'\xff', '\x00'})
+	w.WriteBits(13, 0)
+	if w.Offset() != 32 {
+		t.Error("Offset incorrect. Got", w.Offset(), "wanted 32")
+	}
+}
@@ -1,11 +1,12 @@
 package bzip2
 
 import (
+	"bytes"
 	"errors"
 	"math"
 
-	"github.com/larzconwell/bzip2/internal/bits"
-	"github.com/larzconwell/bzip2/internal/bwt"
+	"github.com/larzconwell/bzip2/bits"
+	"github.com/larzconwell/bzip2/bwt"
 	"github.com/larzconwell/bzip2/internal/crc32"
 	"github.com/larzconwell/bzip2/internal/huffman"
 	"github.com/larzconwell/bzip2/internal/mtf"
@@ -27,14 +28,22 @@ var (
 
 // block handles the compression of data up to a set size.
 type block struct {
-	runs *rle.RunList
-	size int
-	crc  uint32
+	runs    *rle.RunList
+	size    int
+	dataLen int
+	crc     uint32
+	hash    uint64
+	counts  [256]int
+	recent  []byte
+	extreme bool
+	sorter  bwt.Sorter
 }
 
-// newBlock creates a compression block for data up to the given size.
-func newBlock(size int) *block {
-	return &block{runs: rle.NewRunList(), size: size}
+// newBlock creates a compression block for data up to the given
+// size, if extreme is set more time is spent compressing it. The
+// sorter given performs the Burrows-Wheeler Transform.
+func newBlock(size int, extreme bool, sorter bwt.Sorter) *block {
+	return &block{runs: rle.NewRunList(), size: size, extreme: extreme, sorter: sorter}
 }
 
 // Len returns the number of bytes written to the block.
@@ -60,6 +69,7 @@ func (b *block) Write(p []byte) (int, error) {
 	}
 
 	b.crc = crc32.Update(b.crc, p)
+	b.dataLen += len(p)
 	return len(p), err
 }
 
@@ -71,7 +81,7 @@ func (b *block) WriteBlock(bw *bits.Writer) error {
 
 	// BWT step.
 	bwtData := make([]byte, len(rleData))
-	bwtidx := bwt.Transform(bwtData, rleData)
+	bwtidx := b.sorter.Transform(bwtData, rleData)
 
 	// MTF step.
 	mtfData := bwtData
@@ -82,7 +92,7 @@ func (b *block) WriteBlock(bw *bits.Writer) error {
 	freqs := rle2.GetFrequencies(reducedSyms, rle2Data)
 
 	// Setup the huffman trees required to encode rle2Data.
-	trees, selections := huffman.GenerateTrees(freqs, rle2Data)
+	trees, selections := huffman.GenerateTrees(freqs, rle2Data, b.extreme)
 
 	// Get the MTF encoded huffman tree selections.
 	treeSelectionSymbols := make(symbols.ReducedSet, len(trees))
@@ -128,6 +138,48 @@ func (b *block) WriteBlock(bw *bits.Writer) error {
 	return bw.Err()
 }
 
+// Compress compresses the content buffered into memory
+// so the block can be written at a later time.
+func (b *block) Compress() *compressedBlock {
+	var buf bytes.Buffer
+	bw := bits.NewWriter(&buf)
+	err := b.WriteBlock(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+
+	return &compressedBlock{
+		data:    buf.Bytes(),
+		bits:    bw.BufferedBits(),
+		n:       bw.Buffered(),
+		crc:     b.crc,
+		dataLen: b.dataLen,
+		err:     err,
+	}
+}
+
+// compressedBlock is a block compressed into memory, the
+// bits left after the last byte are kept separately.
+type compressedBlock struct {
+	data    []byte
+	bits    uint64
+	n       uint
+	crc     uint32
+	dataLen int
+	err     error
+}
+
+// WriteBlock writes the compressed block to the bit writer given.
+func (cb *compressedBlock) WriteBlock(bw *bits.Writer) error {
+	if cb.err != nil {
+		return cb.err
+	}
+
+	bw.WriteBytes(cb.data)
+	bw.WriteBits(cb.n, cb.bits)
+	return bw.Err()
+}
+
 // writeSymbolBitmaps writes the bitmaps for the used symbols.
 func (b *block) writeSymbolBitmaps(bw *bits.Writer, syms symbols.Set) {
 	rangesUsed := 0
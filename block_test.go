@@ -3,11 +3,12 @@ package bzip2
 import (
 	"testing"
 
+	"github.com/larzconwell/bzip2/bwt"
 	"github.com/larzconwell/bzip2/internal/testhelpers"
 )
 
 func TestBlockFullWrite(t *testing.T) {
-	block := newBlock(1000)
+	block := newBlock(1000, false, bwt.SuffixArray{})
 
 	_, err := block.Write(testhelpers.NoRunData(block.size))
 	if err == nil {
@@ -19,7 +20,7 @@ func TestBlockFullWrite(t *testing.T) {
 }
 
 func TestBlockMultiWrite(t *testing.T) {
-	block := newBlock(1000)
+	block := newBlock(1000, false, bwt.SuffixArray{})
 
 	n, err := block.Write(testhelpers.NoRunData(block.size / 2))
 	if err != nil {
@@ -41,7 +42,7 @@ func TestBlockMultiWrite(t *testing.T) {
 }
 
 func TestBlockOverWrite(t *testing.T) {
-	block := newBlock(1000)
+	block := newBlock(1000, false, bwt.SuffixArray{})
 
 	n, err := block.Write(testhelpers.NoRunData(block.size + 500))
 	if err == nil {
@@ -0,0 +1,4 @@
+// Package bwt implements the Burrows-Wheeler Transform, with the
+// Sorter interface allowing the way a block is transformed when
+// compressing to be replaced.
+package bwt
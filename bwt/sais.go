@@ -0,0 +1,163 @@
+package bwt
+
+// sais builds the suffix array for text in sa using the SA-IS
+// algorithm in linear time. The last value in text must be a
+// unique 0 sentinel, and all values must be less than k.
+//
+// See "Linear Suffix Array Construction by Almost Pure
+// Induced-Sorting" by Nong, Zhang and Chan.
+func sais(text, sa []int32, k int) {
+	n := len(text)
+	buckets := make([]int32, k)
+
+	// Classify each suffix as S-type, smaller than the suffix
+	// after it, or L-type, larger than the suffix after it.
+	stype := make([]bool, n)
+	stype[n-1] = true
+	for i := n - 2; i >= 0; i-- {
+		stype[i] = text[i] < text[i+1] || (text[i] == text[i+1] && stype[i+1])
+	}
+
+	// isLMS checks if the suffix at i is a leftmost S-type suffix.
+	isLMS := func(i int32) bool {
+		return i > 0 && stype[i] && !stype[i-1]
+	}
+
+	// Sort the LMS substrings by placing the LMS suffixes at the
+	// end of their buckets and inducing the rest.
+	for i := range sa {
+		sa[i] = -1
+	}
+	getBuckets(text, buckets, true)
+	for i := int32(1); i < int32(n); i++ {
+		if isLMS(i) {
+			buckets[text[i]]--
+			sa[buckets[text[i]]] = i
+		}
+	}
+	induce(text, sa, stype, buckets)
+
+	// Move the sorted LMS substrings to the front.
+	m := 0
+	for _, pos := range sa {
+		if isLMS(pos) {
+			sa[m] = pos
+			m++
+		}
+	}
+	for i := m; i < n; i++ {
+		sa[i] = -1
+	}
+
+	// Name each LMS substring by its rank, equal substrings sharing
+	// the same name. The names are stored at the back half of sa
+	// in the order the substrings appear in text.
+	names := int32(0)
+	prev := int32(-1)
+	for i := 0; i < m; i++ {
+		pos := sa[i]
+
+		diff := prev == -1
+		for d := int32(0); !diff; d++ {
+			if text[pos+d] != text[prev+d] || stype[pos+d] != stype[prev+d] {
+				diff = true
+			} else if d > 0 && (isLMS(pos+d) || isLMS(prev+d)) {
+				break
+			}
+		}
+		if diff {
+			names++
+			prev = pos
+		}
+
+		sa[m+int(pos/2)] = names - 1
+	}
+	j := n - 1
+	for i := n - 1; i >= m; i-- {
+		if sa[i] >= 0 {
+			sa[j] = sa[i]
+			j--
+		}
+	}
+
+	// Sort the LMS suffixes using the reduced text of names,
+	// recursing if the names aren't unique.
+	reduced := sa[n-m:]
+	reducedSA := sa[:m]
+	if int(names) < m {
+		sais(reduced, reducedSA, int(names))
+	} else {
+		for i, name := range reduced {
+			reducedSA[name] = int32(i)
+		}
+	}
+
+	// Map the sorted reduced suffixes back to their LMS positions.
+	j = 0
+	for i := int32(1); i < int32(n); i++ {
+		if isLMS(i) {
+			reduced[j] = i
+			j++
+		}
+	}
+	for i, r := range reducedSA {
+		reducedSA[i] = reduced[r]
+	}
+	for i := m; i < n; i++ {
+		sa[i] = -1
+	}
+
+	// Induce the full suffix array from the sorted LMS suffixes.
+	getBuckets(text, buckets, true)
+	for i := m - 1; i >= 0; i-- {
+		pos := sa[i]
+		sa[i] = -1
+
+		buckets[text[pos]]--
+		sa[buckets[text[pos]]] = pos
+	}
+	induce(text, sa, stype, buckets)
+}
+
+// getBuckets gets the start, or the end, of each values bucket
+// in the suffix array.
+func getBuckets(text, buckets []int32, end bool) {
+	for i := range buckets {
+		buckets[i] = 0
+	}
+	for _, v := range text {
+		buckets[v]++
+	}
+
+	sum := int32(0)
+	for i, count := range buckets {
+		sum += count
+		if end {
+			buckets[i] = sum
+		} else {
+			buckets[i] = sum - count
+		}
+	}
+}
+
+// induce induces the order of the L-type suffixes and then the
+// S-type suffixes from the suffixes already placed in sa.
+func induce(text, sa []int32, stype []bool, buckets []int32) {
+	getBuckets(text, buckets, false)
+	for i := 0; i < len(sa); i++ {
+		pos := sa[i] - 1
+		if pos >= 0 && !stype[pos] {
+			sa[buckets[text[pos]]] = pos
+			buckets[text[pos]]++
+		}
+	}
+
+	getBuckets(text, buckets, true)
+	for i := len(sa) - 1; i >= 0; i-- {
+		pos := sa[i] - 1
+		if pos >= 0 && stype[pos] {
+			buckets[text[pos]]--
+			sa[buckets[text[pos]]] = pos
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package bwt
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Sorter sorts the rotations of a block for the Burrows-Wheeler
+// Transform. Implementations can trade speed for memory, or be
+// specialized for the data being compressed, as long as the
+// output is the same as sorting the rotations directly.
+type Sorter interface {
+	// Transform performs the Burrows-Wheeler Transform on the src
+	// slice and writes the last byte of each sorted rotation to
+	// dst, which is the same length as src. The index of src in
+	// the sorted rotations is returned, or -1 if src is empty.
+	Transform(dst, src []byte) int
+}
+
+// SuffixArray is a Sorter that uses Transform, building a suffix
+// array in linear time. It uses about 16 bytes of memory for each
+// byte in the block.
+type SuffixArray struct{}
+
+// Transform performs the Burrows-Wheeler Transform using Transform.
+func (SuffixArray) Transform(dst, src []byte) int {
+	return Transform(dst, src)
+}
+
+// RotationSort is a Sorter that sorts the rotations by comparing
+// them directly, using about 4 bytes of memory for each byte in
+// the block. Comparisons scan the prefixes rotations share, so
+// it's slow for highly repetitive data.
+type RotationSort struct{}
+
+// Transform performs the Burrows-Wheeler Transform by sorting
+// the rotations of src.
+func (RotationSort) Transform(dst, src []byte) int {
+	srclen := len(src)
+	if srclen == 0 {
+		return -1
+	}
+
+	rotations := make([]int32, srclen)
+	for i := range rotations {
+		rotations[i] = int32(i)
+	}
+	sort.Slice(rotations, func(i, j int) bool {
+		return compareRotations(src, int(rotations[i]), int(rotations[j])) < 0
+	})
+
+	idx := -1
+	for i, r := range rotations {
+		// If it's the src data, set the index and the last character.
+		if r == 0 {
+			idx = i
+			dst[i] = src[srclen-1]
+		} else {
+			dst[i] = src[r-1]
+		}
+	}
+
+	return idx
+}
+
+// compareRotations compares the rotations of src beginning at i
+// and j, comparing the parts before wrapping around at a time.
+func compareRotations(src []byte, i, j int) int {
+	srclen := len(src)
+	for compared := 0; compared < srclen; {
+		a := src[(i+compared)%srclen:]
+		b := src[(j+compared)%srclen:]
+
+		n := srclen - compared
+		if len(a) < n {
+			n = len(a)
+		}
+		if len(b) < n {
+			n = len(b)
+		}
+
+		c := bytes.Compare(a[:n], b[:n])
+		if c != 0 {
+			return c
+		}
+		compared += n
+	}
+
+	return 0
+}
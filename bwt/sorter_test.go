@@ -0,0 +1,70 @@
+package bwt
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+var sorters = map[string]Sorter{
+	"SuffixArray":  SuffixArray{},
+	"RotationSort": RotationSort{},
+}
+
+func TestSortersMatchRotationSort(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+
+	for name, sorter := range sorters {
+		for i := 0; i < 200; i++ {
+			src := make([]byte, 1+rand.Intn(300))
+			alphabet := 1 + rand.Intn(4)
+			for j := range src {
+				src[j] = byte(rand.Intn(alphabet))
+			}
+			expected := make([]byte, len(src))
+			actual := make([]byte, len(src))
+
+			rotationTransform(expected, src)
+			idx := sorter.Transform(actual, src)
+			if !bytes.Equal(actual, expected) {
+				t.Fatal(name, "output doesn't match sorted rotations for", src)
+			}
+
+			original := make([]byte, len(src))
+			InverseTransform(original, actual, idx)
+			if !bytes.Equal(original, src) {
+				t.Fatal(name, "value idx is incorrect for", src)
+			}
+		}
+	}
+}
+
+func TestSortersPeriodic(t *testing.T) {
+	src := bytes.Repeat([]byte("abcab"), 200)
+	expected := make([]byte, len(src))
+	rotationTransform(expected, src)
+
+	for name, sorter := range sorters {
+		dst := make([]byte, len(src))
+		idx := sorter.Transform(dst, src)
+		if !bytes.Equal(dst, expected) {
+			t.Error(name, "output doesn't match sorted rotations")
+		}
+
+		original := make([]byte, len(src))
+		InverseTransform(original, dst, idx)
+		if !bytes.Equal(original, src) {
+			t.Error(name, "value idx is incorrect")
+		}
+	}
+}
+
+func TestSortersEmpty(t *testing.T) {
+	for name, sorter := range sorters {
+		idx := sorter.Transform(nil, nil)
+		if idx != -1 {
+			t.Error(name, "value idx is incorrect. Got", idx, "wanted -1")
+		}
+	}
+}
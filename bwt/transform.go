@@ -0,0 +1,77 @@
+package bwt
+
+// Transform performs the Burrows-Wheeler Transform on the src
+// slice and writes the results to dst, the index to the original
+// src after sorting is returned.
+//
+// The rotations are sorted by building the suffix array for src
+// repeated twice, the suffixes starting in the first copy of src
+// are sorted in the same order as the rotations.
+func Transform(dst, src []byte) int {
+	srclen := len(src)
+	if srclen == 0 {
+		return -1
+	}
+
+	// Shift the bytes up by one so 0 can be used as the sentinel.
+	text := make([]int32, 2*srclen+1)
+	for i, b := range src {
+		text[i] = int32(b) + 1
+		text[srclen+i] = int32(b) + 1
+	}
+	sa := make([]int32, len(text))
+	sais(text, sa, 257)
+
+	idx := -1
+	i := 0
+	for _, r := range sa {
+		if int(r) >= srclen {
+			continue
+		}
+
+		// If it's the src data, set the index and the last character.
+		if r == 0 {
+			idx = i
+			dst[i] = src[srclen-1]
+		} else {
+			dst[i] = src[r-1]
+		}
+		i++
+	}
+
+	return idx
+}
+
+// InverseTransform reverses the Burrows-Wheeler Transform on the src
+// slice given the index to the original data, writing the results
+// to dst. Idx must be a valid index into src.
+func InverseTransform(dst, src []byte, idx int) {
+	if len(src) == 0 {
+		return
+	}
+
+	// Get the index each byte starts at in the sorted first column.
+	var starts [256]int
+	for _, b := range src {
+		starts[b]++
+	}
+	sum := 0
+	for i, count := range starts {
+		starts[i] = sum
+		sum += count
+	}
+
+	// Link each row in the first column to the row it came from,
+	// following the links from idx reconstructs the original data.
+	next := make([]int, len(src))
+	for i, b := range src {
+		next[starts[b]] = i
+		starts[b]++
+	}
+
+	row := next[idx]
+	for i := range dst {
+		dst[i] = src[row]
+		row = next[row]
+	}
+}
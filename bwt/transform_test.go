@@ -0,0 +1,178 @@
+package bwt
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTransformEven(t *testing.T) {
+	src := []byte("banana")
+	dst := make([]byte, len(src))
+
+	idx := Transform(dst, src)
+	if idx != 3 {
+		t.Error("Value idx is incorrect. Got", idx, "wanted 3")
+	}
+
+	if string(dst) != "nnbaaa" {
+		t.Error("Output is incorrect. Got", string(dst), "wanted nnbaaa")
+	}
+}
+
+func TestTransformOdd(t *testing.T) {
+	src := []byte("baanana")
+	dst := make([]byte, len(src))
+
+	idx := Transform(dst, src)
+	if idx != 4 {
+		t.Error("Value idx is incorrect. Got", idx, "wanted 4")
+	}
+
+	if string(dst) != "bnnaaaa" {
+		t.Error("Output is incorrect. Got", string(dst), "wanted bnnaaaa")
+	}
+}
+
+func TestTransformAfterRLE(t *testing.T) {
+	src := []byte("baaaa\x00nana")
+	dst := make([]byte, len(src))
+
+	idx := Transform(dst, src)
+	if idx != 7 {
+		t.Error("Value idx is incorrect. Got", idx, "wanted 7")
+	}
+
+	if string(dst) != "aaaabnnaa\x00" {
+		t.Error("Output is incorrect. Got", string(dst), "wanted aaaabnnaa\\0")
+	}
+}
+
+// rotationTransform performs the Burrows-Wheeler Transform by
+// sorting each rotation directly.
+func rotationTransform(dst, src []byte) {
+	rotations := make([][]byte, len(src))
+	for i := range rotations {
+		rotations[i] = append(append([]byte{}, src[i:]...), src[:i]...)
+	}
+	sort.Slice(rotations, func(i, j int) bool {
+		return bytes.Compare(rotations[i], rotations[j]) == -1
+	})
+
+	for i, rotation := range rotations {
+		dst[i] = rotation[len(rotation)-1]
+	}
+}
+
+func TestTransformMatchesRotationSort(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+
+	for i := 0; i < 200; i++ {
+		src := make([]byte, 1+rand.Intn(300))
+		alphabet := 1 + rand.Intn(4)
+		for j := range src {
+			src[j] = byte(rand.Intn(alphabet))
+		}
+		expected := make([]byte, len(src))
+		actual := make([]byte, len(src))
+
+		rotationTransform(expected, src)
+		idx := Transform(actual, src)
+		if !bytes.Equal(actual, expected) {
+			t.Fatal("Output doesn't match sorted rotations for", src)
+		}
+
+		original := make([]byte, len(src))
+		InverseTransform(original, actual, idx)
+		if !bytes.Equal(original, src) {
+			t.Fatal("Value idx is incorrect for", src)
+		}
+	}
+}
+
+func TestTransformPeriodic(t *testing.T) {
+	src := bytes.Repeat([]byte("abcab"), 1000)
+	dst := make([]byte, len(src))
+	expected := make([]byte, len(src))
+
+	rotationTransform(expected, src)
+	idx := Transform(dst, src)
+	if !bytes.Equal(dst, expected) {
+		t.Error("Output doesn't match sorted rotations")
+	}
+
+	original := make([]byte, len(src))
+	InverseTransform(original, dst, idx)
+	if !bytes.Equal(original, src) {
+		t.Error("Value idx is incorrect")
+	}
+}
+
+func TestInverseTransform(t *testing.T) {
+	src := []byte("nnbaaa")
+	dst := make([]byte, len(src))
+
+	InverseTransform(dst, src, 3)
+	if string(dst) != "banana" {
+		t.Error("Output is incorrect. Got", string(dst), "wanted banana")
+	}
+}
+
+func TestInverseTransformRoundTrip(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+
+	src := make([]byte, 10000)
+	bwt := make([]byte, len(src))
+	dst := make([]byte, len(src))
+	for i := range src {
+		src[i] = byte(rand.Intn(4))
+	}
+
+	idx := Transform(bwt, src)
+	InverseTransform(dst, bwt, idx)
+	if string(dst) != string(src) {
+		t.Error("Output is incorrect")
+	}
+}
+
+func BenchmarkTransform(b *testing.B) {
+	rand.Seed(time.Now().UnixNano())
+
+	src := make([]byte, 100000)
+	dst := make([]byte, len(src))
+	for i := range src {
+		src[i] = byte(rand.Intn(256))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Transform(dst, src)
+	}
+}
+
+func BenchmarkTransformLarge(b *testing.B) {
+	rand.Seed(time.Now().UnixNano())
+
+	src := make([]byte, 100000*6)
+	dst := make([]byte, len(src))
+	for i := range src {
+		src[i] = byte(rand.Intn(256))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Transform(dst, src)
+	}
+}
+
+func BenchmarkTransformRepetitive(b *testing.B) {
+	src := bytes.Repeat([]byte("2015-06-01 12:00:00 INFO request handled\n"), 900000/41)
+	dst := make([]byte, len(src))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Transform(dst, src)
+	}
+}
@@ -0,0 +1,114 @@
+package bzip2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// errInvalidCheckpoint occurs when resuming from a
+	// checkpoint with invalid values.
+	errInvalidCheckpoint = errors.New("bzip2: invalid checkpoint")
+)
+
+// Checkpoint is the state of a Writer after a block has been
+// written, so a long running Writer can be resumed after a crash.
+// It only contains exported fields so it can be stored with
+// packages like encoding/json or encoding/gob.
+//
+// To resume, the output is truncated to Offset/8 bytes and given
+// to NewWriterCheckpoint, then the input is written again from the
+// Input offset. As long as the same options and input are used the
+// output is identical to a Writer that was never interrupted.
+type Checkpoint struct {
+	// Offset is the number of bits written to the output.
+	Offset int64
+	// Bits holds the Offset%8 bits after the last full byte
+	// written, which are written again when resuming.
+	Bits byte
+	// CRC is the combined crc of the blocks in the current stream.
+	CRC uint32
+	// Level is the compression level.
+	Level int
+	// Input is the number of uncompressed bytes in the blocks written.
+	Input int64
+	// StreamBlocks is the number of blocks in the current stream,
+	// if zero the stream has ended and the next block begins a new
+	// stream.
+	StreamBlocks int
+}
+
+// NewWriterCheckpoint returns a new Writer that resumes writing
+// from a Checkpoint, writing to w which has to contain the first
+// cp.Offset/8 bytes of the output. The options given are used
+// like NewWriterOptions, but the level comes from the checkpoint.
+// Index returns nil, since the blocks before the checkpoint aren't
+// known, and StreamOffsets only includes streams begun after it.
+//
+// If the options and checkpoint are valid then the error returned
+// will be nil. Otherwise the error returned will be non-nil.
+func NewWriterCheckpoint(w io.Writer, cp *Checkpoint, opts *WriterOptions) (*Writer, error) {
+	if opts.Level != 0 && opts.Level != cp.Level {
+		return nil, fmt.Errorf("bzip2: level %d doesn't match checkpoint level %d",
+			opts.Level, cp.Level)
+	}
+	if cp.Offset < 0 || cp.Input < 0 || cp.StreamBlocks < 0 {
+		return nil, errInvalidCheckpoint
+	}
+
+	resumeOpts := *opts
+	resumeOpts.Level = cp.Level
+	writer, err := NewWriterOptions(w, &resumeOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	leading := uint(cp.Offset % 8)
+	writer.bw.WriteBits(leading, uint64(cp.Bits)&(1<<leading-1))
+	writer.base = cp.Offset - int64(leading)
+	writer.crc = cp.CRC
+	writer.input = cp.Input
+	writer.wroteHeader = cp.StreamBlocks > 0
+	writer.streamEnded = cp.StreamBlocks == 0 && cp.Offset > 0
+	writer.streamBlocks = cp.StreamBlocks
+	writer.writtenBlocks = cp.StreamBlocks
+	writer.continued = true
+	return writer, nil
+}
+
+// blockWritten updates the state after a block is written to the
+// bit writer, taking a checkpoint unless the block ends the stream.
+func (w *Writer) blockWritten(dataLen int, crc uint32) error {
+	w.crc = ((w.crc << 1) | (w.crc >> 31)) ^ crc
+	w.input += int64(dataLen)
+	w.writtenBlocks++
+
+	// The checkpoint is taken once the stream has ended.
+	if w.writtenBlocks == w.blockLimit {
+		return nil
+	}
+	return w.checkpoint()
+}
+
+// checkpoint flushes the bit writer and gives the
+// current state to the checkpoint function.
+func (w *Writer) checkpoint() error {
+	if w.checkpointFn == nil {
+		return nil
+	}
+
+	err := w.bw.Flush()
+	if err != nil {
+		return err
+	}
+
+	return w.checkpointFn(Checkpoint{
+		Offset:       w.offset(),
+		Bits:         byte(w.bw.BufferedBits()),
+		CRC:          w.crc,
+		Level:        w.level(),
+		Input:        w.input,
+		StreamBlocks: w.writtenBlocks,
+	})
+}
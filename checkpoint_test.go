@@ -0,0 +1,143 @@
+package bzip2
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/larzconwell/bzip2/internal/testhelpers"
+)
+
+// checkpointWrite writes data with the options given, keeping
+// the checkpoints taken. The output is returned.
+func checkpointWrite(t *testing.T, data []byte, opts WriterOptions, checkpoints *[]Checkpoint) []byte {
+	var buf bytes.Buffer
+	opts.Checkpoint = func(cp Checkpoint) error {
+		// Everything before the checkpoint is written.
+		if int64(buf.Len()) != cp.Offset/8 {
+			t.Error("Output not flushed at checkpoint. Got", buf.Len(), "bytes wanted", cp.Offset/8)
+		}
+
+		*checkpoints = append(*checkpoints, cp)
+		return nil
+	}
+
+	writer, err := NewWriterOptions(&buf, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write(data)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestCheckpointResume(t *testing.T) {
+	data := append(linesData(), linesData()...)
+
+	for _, test := range []struct {
+		data []byte
+		opts WriterOptions
+	}{
+		{data, WriterOptions{Level: BestSpeed}},
+		{data, WriterOptions{Level: BestSpeed, Concurrency: 4}},
+		{data, WriterOptions{Level: BestSpeed, StreamBlocks: 2}},
+		{data, WriterOptions{Level: BestSpeed, StreamBlocks: 2, Concurrency: 4}},
+		{data, WriterOptions{Level: BestSpeed, Rsyncable: true, Concurrency: 4}},
+		{data, WriterOptions{Level: BestSpeed, AdaptiveBlocks: true}},
+		// The block fills during the write, ending the stream before Close.
+		{testhelpers.NoRunData(baseBlockSize), WriterOptions{Level: BestSpeed, StreamBlocks: 1}},
+	} {
+		var checkpoints []Checkpoint
+		expected := checkpointWrite(t, test.data, test.opts, &checkpoints)
+		if len(checkpoints) == 0 {
+			t.Fatal("Checkpoint should be taken after each block")
+		}
+
+		// Resume from each checkpoint with the output truncated.
+		for _, cp := range checkpoints {
+			buf := bytes.NewBuffer(append([]byte(nil), expected[:cp.Offset/8]...))
+			writer, err := NewWriterCheckpoint(buf, &cp, &test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = writer.Write(test.data[cp.Input:])
+			if err == nil {
+				err = writer.Close()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(buf.Bytes(), expected) {
+				t.Fatal("Resumed output doesn't match with options", test.opts.Concurrency,
+					test.opts.StreamBlocks, "from checkpoint", cp)
+			}
+			if writer.Index() != nil {
+				t.Error("Resumed writer shouldn't give an index. Got", writer.Index())
+			}
+		}
+	}
+}
+
+func TestCheckpointLevelMismatch(t *testing.T) {
+	_, err := NewWriterCheckpoint(&bytes.Buffer{}, &Checkpoint{Level: BestSpeed},
+		&WriterOptions{Level: BestCompression})
+	if err == nil {
+		t.Error("Resuming with a different level should fail")
+	}
+}
+
+func TestCheckpointExtremeCompression(t *testing.T) {
+	var checkpoints []Checkpoint
+	var expected bytes.Buffer
+	writer, err := NewWriterOptions(&expected, &WriterOptions{
+		Level: ExtremeCompression,
+		Checkpoint: func(cp Checkpoint) error {
+			checkpoints = append(checkpoints, cp)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = writer.Write([]byte("banana"))
+	if err == nil {
+		err = writer.NewStream()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The extreme level is kept, even though the block size is the same as level 9.
+	cp := checkpoints[len(checkpoints)-1]
+	if cp.Level != ExtremeCompression {
+		t.Fatal("Checkpoint should have the extreme level. Got", cp.Level)
+	}
+
+	buf := bytes.NewBuffer(append([]byte(nil), expected.Bytes()...))
+	resumed, err := NewWriterCheckpoint(buf, &cp, &WriterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, w := range []*Writer{writer, resumed} {
+		_, err = w.Write([]byte("apple"))
+		if err == nil {
+			err = w.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+		t.Error("Resumed output doesn't match with the extreme level")
+	}
+}
@@ -0,0 +1,27 @@
+// Command bzip2 compresses and decompresses files using the bzip2 format,
+// accepting the same flags as the reference bzip2 program.
+//
+// Usage:
+//
+//	bzip2 [flags] [files...]
+//
+// The flags are:
+//
+//	-z, --compress    compress the files, the default
+//	-d, --decompress  decompress the files
+//	-t, --test        test the integrity of the compressed files
+//	-c, --stdout      write to standard output and keep the files
+//	-k, --keep        keep the input files
+//	-f, --force       overwrite existing output files
+//	-v, --verbose     print details about each file, and each block when testing
+//	-1 .. -9          block size of 100k .. 900k, -9 is the default
+//	--fast, --best    aliases for -1 and -9
+//
+// With no files standard input is compressed or decompressed to
+// standard output. When run as bunzip2 the files are decompressed,
+// and bzcat decompresses them to standard output.
+//
+// Compressed files are named with the .bz2 suffix, when decompressing
+// the .bz2 and .bz suffixes are removed and .tbz2 and .tbz are replaced
+// with .tar. The mode and modification time of each file are preserved.
+package main
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/larzconwell/bzip2"
+)
+
+// Exit codes, the same as the reference bzip2.
+const (
+	exitOK          = 0
+	exitEnvironment = 1
+	exitCorrupt     = 2
+)
+
+// mode is the operation performed on each file.
+type mode int
+
+const (
+	compressMode mode = iota
+	decompressMode
+	testMode
+)
+
+// options are the options given on the command line.
+type options struct {
+	mode    mode
+	stdout  bool
+	keep    bool
+	force   bool
+	verbose bool
+	level   int
+	files   []string
+}
+
+// suffixes maps compressed file suffixes to the suffix
+// used for the decompressed file.
+var suffixes = []struct {
+	compressed   string
+	decompressed string
+}{
+	{".bz2", ""},
+	{".bz", ""},
+	{".tbz2", ".tar"},
+	{".tbz", ".tar"},
+}
+
+func main() {
+	os.Exit(run(filepath.Base(os.Args[0]), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command named name with args, returning the exit code.
+func run(name string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts, err := parseArgs(name, args)
+	if err != nil {
+		fmt.Fprintf(stderr, "bzip2: %s\n", err)
+		return exitEnvironment
+	}
+
+	toStdout := len(opts.files) == 0 || opts.stdout
+	if toStdout && opts.mode == compressMode && !opts.force && isTerminal(stdout) {
+		fmt.Fprintln(stderr, "bzip2: I won't write compressed data to a terminal.")
+		return exitEnvironment
+	}
+
+	if len(opts.files) == 0 {
+		if opts.mode == testMode {
+			err = testStream(opts, "(stdin)", stdin, stderr)
+		} else {
+			err = processStream(opts, stdout, stdin)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "bzip2: (stdin): %s\n", errorString(err))
+			return exitCode(err)
+		}
+
+		return exitOK
+	}
+
+	code := exitOK
+	for _, file := range opts.files {
+		err = processFile(opts, file, stdout, stderr)
+		if err != nil {
+			fmt.Fprintf(stderr, "bzip2: %s: %s\n", file, errorString(err))
+
+			if c := exitCode(err); c > code {
+				code = c
+			}
+		}
+	}
+
+	return code
+}
+
+// parseArgs parses the command line arguments. Short flags can
+// be combined like the reference bzip2, e.g. -dc or -kv9.
+func parseArgs(name string, args []string) (*options, error) {
+	opts := &options{level: bzip2.BestCompression}
+
+	switch strings.TrimSuffix(name, ".exe") {
+	case "bunzip2":
+		opts.mode = decompressMode
+	case "bzcat":
+		opts.mode = decompressMode
+		opts.stdout = true
+	}
+
+	flagsDone := false
+	for _, arg := range args {
+		if flagsDone || arg == "-" || !strings.HasPrefix(arg, "-") {
+			opts.files = append(opts.files, arg)
+			continue
+		}
+		if arg == "--" {
+			flagsDone = true
+			continue
+		}
+
+		if strings.HasPrefix(arg, "--") {
+			err := opts.setLong(arg[2:])
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		for _, c := range arg[1:] {
+			err := opts.setShort(c)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// setShort sets the option for a short flag.
+func (opts *options) setShort(c rune) error {
+	switch {
+	case c == 'z':
+		opts.mode = compressMode
+	case c == 'd':
+		opts.mode = decompressMode
+	case c == 't':
+		opts.mode = testMode
+	case c == 'c':
+		opts.stdout = true
+	case c == 'k':
+		opts.keep = true
+	case c == 'f':
+		opts.force = true
+	case c == 'v':
+		opts.verbose = true
+	case c >= '1' && c <= '9':
+		opts.level = int(c - '0')
+	default:
+		return fmt.Errorf("invalid flag: -%c", c)
+	}
+
+	return nil
+}
+
+// setLong sets the option for a long flag.
+func (opts *options) setLong(flag string) error {
+	switch flag {
+	case "compress":
+		opts.mode = compressMode
+	case "decompress":
+		opts.mode = decompressMode
+	case "test":
+		opts.mode = testMode
+	case "stdout":
+		opts.stdout = true
+	case "keep":
+		opts.keep = true
+	case "force":
+		opts.force = true
+	case "verbose":
+		opts.verbose = true
+	case "fast":
+		opts.level = bzip2.BestSpeed
+	case "best":
+		opts.level = bzip2.BestCompression
+	default:
+		return fmt.Errorf("invalid flag: --%s", flag)
+	}
+
+	return nil
+}
+
+// processFile compresses, decompresses or tests the file named name.
+func processFile(opts *options, name string, stdout, stderr io.Writer) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return errors.New("not a regular file")
+	}
+
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if opts.mode == testMode {
+		return testStream(opts, name, in, stderr)
+	}
+	if opts.stdout {
+		return processStream(opts, stdout, in)
+	}
+
+	var outName string
+	if opts.mode == compressMode {
+		if _, ok := decompressedName(name); ok {
+			return errors.New("already has a compressed suffix")
+		}
+
+		outName = name + ".bz2"
+	} else {
+		var ok bool
+		outName, ok = decompressedName(name)
+		if !ok {
+			outName = name + ".out"
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if opts.force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	out, err := os.OpenFile(outName, flags, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	counter := &countWriter{w: out}
+	err = processStream(opts, counter, in)
+	if err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+	if err == nil {
+		err = os.Chmod(outName, info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Chtimes(outName, info.ModTime(), info.ModTime())
+	}
+	if err != nil {
+		os.Remove(outName)
+		return err
+	}
+
+	if opts.verbose {
+		printStats(stderr, opts.mode, name, info.Size(), counter.n)
+	}
+	if !opts.keep {
+		return os.Remove(name)
+	}
+
+	return nil
+}
+
+// processStream compresses or decompresses the data
+// from r, writing the results to w.
+func processStream(opts *options, w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+
+	switch opts.mode {
+	case compressMode:
+		writer, err := bzip2.NewWriterOptions(bw, &bzip2.WriterOptions{
+			Level:       opts.level,
+			Concurrency: runtime.NumCPU(),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(writer, r)
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			return err
+		}
+	case decompressMode:
+		reader := bzip2.NewReaderOptions(r, &bzip2.ReaderOptions{
+			Concurrency: runtime.NumCPU(),
+		})
+		_, err := io.Copy(bw, reader)
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// testStream tests the integrity of the data from r, if verbose the
+// results for each block are printed. If a block fails its position
+// is included in the error.
+func testStream(opts *options, name string, r io.Reader, stderr io.Writer) error {
+	report, err := bzip2.Verify(bufio.NewReader(r))
+	if opts.verbose {
+		fmt.Fprintf(stderr, "  %s:\n", name)
+
+		for i, block := range report.Blocks {
+			status := "ok"
+			if block.Err != nil {
+				status = errorString(block.Err)
+			}
+
+			fmt.Fprintf(stderr, "    [%d: stream %d, bit offset %d, %d bytes, crc 0x%08x] %s\n",
+				i+1, block.Stream+1, block.Offset, block.Size, block.CRC, status)
+		}
+	}
+	if err != nil {
+		if report.Failed >= 0 {
+			block := report.Blocks[report.Failed]
+			return fmt.Errorf("block %d at bit offset %d: %w", report.Failed+1, block.Offset, err)
+		}
+
+		return err
+	}
+
+	if opts.verbose {
+		fmt.Fprintf(stderr, "  %s: ok, %d streams, %d blocks\n", name,
+			report.Streams, len(report.Blocks))
+	}
+	return nil
+}
+
+// printStats prints the details about a file that was processed.
+func printStats(w io.Writer, m mode, name string, in, out int64) {
+	if m == decompressMode {
+		fmt.Fprintf(w, "  %s: done\n", name)
+		return
+	}
+
+	ratio := 0.0
+	bitsPerByte := 0.0
+	saved := 0.0
+	if in > 0 && out > 0 {
+		ratio = float64(in) / float64(out)
+		bitsPerByte = 8 * float64(out) / float64(in)
+		saved = 100 * (1 - float64(out)/float64(in))
+	}
+
+	fmt.Fprintf(w, "  %s: %6.3f:1, %6.3f bits/byte, %5.2f%% saved, %d in, %d out.\n",
+		name, ratio, bitsPerByte, saved, in, out)
+}
+
+// decompressedName gets the name for the decompressed form of
+// the file name, if it has a compressed suffix.
+func decompressedName(name string) (string, bool) {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix.compressed) && len(name) > len(suffix.compressed) {
+			return strings.TrimSuffix(name, suffix.compressed) + suffix.decompressed, true
+		}
+	}
+
+	return name, false
+}
+
+// exitCode gets the exit code for the error, corrupt data
+// is distinguished from other errors.
+func exitCode(err error) int {
+	var structural bzip2.StructuralError
+	if errors.As(err, &structural) || errors.Is(err, bzip2.ErrChecksum) ||
+		errors.Is(err, bzip2.ErrHeader) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return exitCorrupt
+	}
+
+	return exitEnvironment
+}
+
+// errorString gets the message for the error without the
+// package prefixes, since the command already has one.
+func errorString(err error) string {
+	return strings.Replace(err.Error(), "bzip2: ", "", -1)
+}
+
+// isTerminal checks if w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// countWriter counts the bytes written to an io.Writer.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes p to the underlying io.Writer.
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseArgs(t *testing.T) {
+	opts, err := parseArgs("bzip2", []string{"-dkv1", "--force", "a.bz2", "--", "-b.bz2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.mode != decompressMode || !opts.keep || !opts.verbose ||
+		!opts.force || opts.stdout || opts.level != 1 {
+		t.Error("Flags parsed incorrectly. Got", *opts)
+	}
+	if len(opts.files) != 2 || opts.files[0] != "a.bz2" || opts.files[1] != "-b.bz2" {
+		t.Error("Files parsed incorrectly. Got", opts.files)
+	}
+}
+
+func TestParseArgsName(t *testing.T) {
+	opts, err := parseArgs("bzcat", []string{"--best"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.mode != decompressMode || !opts.stdout || opts.level != 9 {
+		t.Error("Flags parsed incorrectly. Got", *opts)
+	}
+}
+
+func TestParseArgsInvalid(t *testing.T) {
+	_, err := parseArgs("bzip2", []string{"-x"})
+	if err == nil {
+		t.Error("Invalid short flag should return an error")
+	}
+
+	_, err = parseArgs("bzip2", []string{"--invalid"})
+	if err == nil {
+		t.Error("Invalid long flag should return an error")
+	}
+}
+
+func TestDecompressedName(t *testing.T) {
+	names := map[string]string{
+		"a.bz2":  "a",
+		"a.bz":   "a",
+		"a.tbz2": "a.tar",
+		"a.tbz":  "a.tar",
+	}
+	for name, expected := range names {
+		actual, ok := decompressedName(name)
+		if !ok || actual != expected {
+			t.Error("Decompressed name for", name, "is incorrect. Got", actual)
+		}
+	}
+
+	_, ok := decompressedName("a.txt")
+	if ok {
+		t.Error("Names without a compressed suffix should not be found")
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzip2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "file.txt")
+	expected := bytes.Repeat([]byte("banana"), 1000)
+	err = ioutil.WriteFile(name, expected, 0640)
+	if err == nil {
+		mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+		err = os.Chtimes(name, mtime, mtime)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run("bzip2", []string{name}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatal("Compressing failed", stderr.String())
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Error("Input file should have been removed")
+	}
+
+	compressed, err := os.Stat(name + ".bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compressed.Mode() != info.Mode() || !compressed.ModTime().Equal(info.ModTime()) {
+		t.Error("Mode and modification time should be preserved")
+	}
+
+	code = run("bzip2", []string{"-t", name + ".bz2"}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatal("Testing failed", stderr.String())
+	}
+
+	code = run("bunzip2", []string{name + ".bz2"}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatal("Decompressing failed", stderr.String())
+	}
+
+	actual, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Error("Decompressed file is incorrect")
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	var compressed, out, stderr bytes.Buffer
+	expected := []byte("banana")
+
+	code := run("bzip2", nil, bytes.NewReader(expected), &compressed, &stderr)
+	if code != exitOK {
+		t.Fatal("Compressing failed", stderr.String())
+	}
+
+	code = run("bzip2", []string{"-d"}, &compressed, &out, &stderr)
+	if code != exitOK {
+		t.Fatal("Decompressing failed", stderr.String())
+	}
+
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Error("Output is incorrect. Got", out.String(), "wanted banana")
+	}
+}
+
+func TestRunCorrupt(t *testing.T) {
+	var out, stderr bytes.Buffer
+
+	code := run("bzip2", []string{"-t"}, bytes.NewReader([]byte("BZh9corrupt")), &out, &stderr)
+	if code != exitCorrupt {
+		t.Error("Corrupt data should exit with", exitCorrupt, "got", code)
+	}
+}
+
+func TestRunTestVerbose(t *testing.T) {
+	var compressed, out, stderr bytes.Buffer
+
+	code := run("bzip2", nil, bytes.NewReader([]byte("banana")), &compressed, &stderr)
+	if code != exitOK {
+		t.Fatal("Compressing failed", stderr.String())
+	}
+
+	stderr.Reset()
+	code = run("bzip2", []string{"-tv"}, &compressed, &out, &stderr)
+	if code != exitOK {
+		t.Fatal("Testing failed", stderr.String())
+	}
+
+	if !bytes.Contains(stderr.Bytes(), []byte("[1: stream 1, bit offset 32, 6 bytes")) {
+		t.Error("Block results should be printed. Got", stderr.String())
+	}
+}
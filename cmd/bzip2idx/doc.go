@@ -0,0 +1,16 @@
+// Command bzip2idx generates and validates index files for bzip2 files.
+//
+// Usage:
+//
+//	bzip2idx [-f] [-t] file.bz2 ...
+//
+// For each file given an index file named after it with ".idx" appended
+// is generated, locating each block so the file can be read from any
+// offset without decompressing it from the beginning. Existing index
+// files aren't overwritten unless -f is given.
+//
+// With -t the existing index files are validated instead, checking each
+// block they locate decodes and matches the size and crc recorded.
+//
+// The index file format is documented in the bzip2 package.
+package main
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/larzconwell/bzip2"
+)
+
+// indexSuffix is appended to the name of the
+// bzip2 file to get the index files name.
+const indexSuffix = ".idx"
+
+func main() {
+	force := flag.Bool("f", false, "overwrite existing index files")
+	test := flag.Bool("t", false, "validate existing index files")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: bzip2idx [-f] [-t] file.bz2 ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	code := 0
+	for _, name := range flag.Args() {
+		var err error
+		if *test {
+			err = validate(name, os.Stdout)
+		} else {
+			err = generate(name, *force, os.Stdout)
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bzip2idx: %s: %s\n", name, err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// generate builds the index for the file named name and writes
+// it to the index file, printing the results to w.
+func generate(name string, force bool, w io.Writer) error {
+	indexName := name + indexSuffix
+	if !force {
+		_, err := os.Stat(indexName)
+		if err == nil {
+			return fmt.Errorf("%s already exists", indexName)
+		}
+	}
+
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	index, err := bzip2.BuildIndex(bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(indexName)
+	if err != nil {
+		return err
+	}
+	_, err = index.WriteTo(out)
+	if err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+	if err != nil {
+		os.Remove(indexName)
+		return err
+	}
+
+	fmt.Fprintf(w, "%s: %d blocks indexed\n", name, len(index.Blocks))
+	return nil
+}
+
+// validate checks the index file for the file named name
+// against its blocks, printing the results to w.
+func validate(name string, w io.Writer) error {
+	indexFile, err := os.Open(name + indexSuffix)
+	if err != nil {
+		return err
+	}
+	defer indexFile.Close()
+
+	index, err := bzip2.ReadIndex(indexFile)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	err = index.Verify(in)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s: ok, %d blocks\n", name, len(index.Blocks))
+	return nil
+}
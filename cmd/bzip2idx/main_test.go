@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/larzconwell/bzip2"
+)
+
+func TestGenerateValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzip2idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	compressed, err := ioutil.ReadFile("../../testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "lines.txt.bz2")
+	err = ioutil.WriteFile(name, compressed, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = generate(name, false, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("3 blocks indexed")) {
+		t.Error("Results should be printed. Got", out.String())
+	}
+
+	err = generate(name, false, &out)
+	if err == nil {
+		t.Error("Existing index file shouldn't be overwritten without force")
+	}
+
+	err = validate(name, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the first blocks crc, directly after its block magic.
+	compressed[10] ^= 0xff
+	err = ioutil.WriteFile(name, compressed, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = validate(name, &out)
+	if err == nil {
+		t.Error("Index shouldn't validate against corrupt data")
+	}
+}
+
+func TestGenerateEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzip2idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var compressed bytes.Buffer
+	err = bzip2.NewWriter(&compressed).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "empty.bz2")
+	err = ioutil.WriteFile(name, compressed.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = generate(name, false, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("0 blocks indexed")) {
+		t.Error("Results should be printed. Got", out.String())
+	}
+
+	err = validate(name, &out)
+	if err != nil {
+		t.Error("Index of an empty stream should validate. Got", err)
+	}
+}
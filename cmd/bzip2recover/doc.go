@@ -0,0 +1,11 @@
+// Command bzip2recover salvages the intact blocks from a damaged bzip2 file.
+//
+// Usage:
+//
+//	bzip2recover file.bz2
+//
+// Each block found is written as its own file, named rec00001file.bz2,
+// rec00002file.bz2 and so on, in the same directory as the damaged file.
+// Whether each block verified is printed, the blocks that did can be
+// decompressed and concatenated to get back the data they contain.
+package main
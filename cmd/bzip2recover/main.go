@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/larzconwell/bzip2"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: bzip2recover file.bz2")
+		os.Exit(1)
+	}
+
+	err := run(os.Args[1], os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bzip2recover: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run recovers the blocks in the file named name, writing each
+// to its own file and printing the results to w.
+func run(name string, w io.Writer) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	dir, base := filepath.Split(name)
+	blocks := 0
+	recovered := 0
+	err = bzip2.Recover(bufio.NewReader(in), func(block *bzip2.RecoveredBlock) error {
+		blocks++
+		outName := filepath.Join(dir, fmt.Sprintf("rec%05d%s", blocks, base))
+
+		status := "ok"
+		if block.Err != nil {
+			status = block.Err.Error()
+		} else {
+			recovered++
+		}
+		fmt.Fprintf(w, "  block %d runs from bit %d to %d: %s\n", blocks,
+			block.Offset, block.Offset+block.Len-1, status)
+
+		return ioutil.WriteFile(outName, block.Data, 0644)
+	})
+	if err != nil {
+		return err
+	}
+
+	if blocks == 0 {
+		return fmt.Errorf("%s: no blocks found", name)
+	}
+	fmt.Fprintf(w, "%d of %d blocks recovered\n", recovered, blocks)
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/larzconwell/bzip2"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzip2recover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	writer := bzip2.NewWriter(&buf)
+	_, err = writer.Write([]byte("banana"))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(dir, "file.bz2")
+	err = ioutil.WriteFile(name, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = run(name, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := os.Open(filepath.Join(dir, "rec00001file.bz2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+
+	data, err := ioutil.ReadAll(bzip2.NewReader(recovered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "banana" {
+		t.Error("Recovered data is incorrect. Got", string(data))
+	}
+
+	if !bytes.Contains(out.Bytes(), []byte("1 of 1 blocks recovered")) {
+		t.Error("Results should be printed. Got", out.String())
+	}
+}
@@ -0,0 +1,18 @@
+// Command bzip2split splits a bzip2 file at block boundaries without
+// compressing the data again.
+//
+// Usage:
+//
+//	bzip2split [-n blocks] [-s size] file.bz2
+//
+// Each part is a standalone bzip2 file containing a contiguous run of
+// whole blocks. With -n each part contains the number of blocks given,
+// with -s each part ends after the block that reaches the compressed
+// size given, which can end in K, M or G. If both are given a part ends
+// once it reaches either.
+//
+// The parts are written next to the file, numbered from 1 before the
+// .bz2 extension, so file.txt.bz2 is split into file.txt.00001.bz2,
+// file.txt.00002.bz2 and so on. Decompressing and concatenating the
+// parts gets back the data in the original file.
+package main
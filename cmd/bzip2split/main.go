@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/larzconwell/bzip2"
+)
+
+func main() {
+	blocks := flag.Int("n", 0, "number of blocks in each part")
+	size := flag.String("s", "", "compressed size of each part, with an optional K, M or G suffix")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: bzip2split [-n blocks] [-s size] file.bz2")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	opts := &bzip2.SplitOptions{Blocks: *blocks}
+	if *size != "" {
+		var err error
+		opts.Size, err = parseSize(*size)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bzip2split: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	err := run(flag.Arg(0), opts, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bzip2split: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run splits the file named name using the options given,
+// printing the name of each part to w.
+func run(name string, opts *bzip2.SplitOptions, w io.Writer) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var names []string
+	err = bzip2.Split(in, opts, func(part int) (io.WriteCloser, error) {
+		partName := partName(name, part+1)
+		names = append(names, partName)
+
+		return os.Create(partName)
+	})
+	if err != nil {
+		// Parts from damaged data aren't kept.
+		for _, partName := range names {
+			os.Remove(partName)
+		}
+
+		return err
+	}
+
+	for _, partName := range names {
+		fmt.Fprintln(w, partName)
+	}
+	fmt.Fprintf(w, "%s split into %d parts\n", name, len(names))
+	return nil
+}
+
+// partName gets the name of the numbered part of
+// the file named name.
+func partName(name string, part int) string {
+	return fmt.Sprintf("%s.%05d.bz2", strings.TrimSuffix(name, ".bz2"), part)
+}
+
+// parseSize parses a size in bytes, with an
+// optional K, M or G suffix.
+func parseSize(s string) (int64, error) {
+	digits := s
+	multiplier := int64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier != 1 {
+		digits = s[:len(s)-1]
+	}
+
+	size, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	return size * multiplier, nil
+}
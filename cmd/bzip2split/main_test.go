@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bz2 "github.com/larzconwell/bzip2"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzip2split")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	compressed, err := ioutil.ReadFile("../../testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(dir, "lines.txt.bz2")
+	err = ioutil.WriteFile(name, compressed, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = run(name, &bz2.SplitOptions{Blocks: 2}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("split into 2 parts")) {
+		t.Error("Results should be printed. Got", out.String())
+	}
+
+	var data []byte
+	for _, part := range []string{"lines.txt.00001.bz2", "lines.txt.00002.bz2"} {
+		partData, err := ioutil.ReadFile(filepath.Join(dir, part))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decompressed, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(partData)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, decompressed...)
+	}
+
+	if !bytes.Equal(data, expected) {
+		t.Error("Output of the parts is incorrect")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	expected := map[string]int64{"100": 100, "2K": 2048, "3m": 3 << 20, "1G": 1 << 30}
+	for s, e := range expected {
+		size, err := parseSize(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != e {
+			t.Error("Size incorrect for", s, "Got", size, "wanted", e)
+		}
+	}
+
+	_, err := parseSize("-1")
+	if err == nil {
+		t.Error("Negative size should fail")
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/larzconwell/bzip2
+
+go 1.16
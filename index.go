@@ -0,0 +1,221 @@
+package bzip2
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/larzconwell/bzip2/bits"
+)
+
+var (
+	// errNegativePosition occurs when seeking or reading
+	// before the beginning of the data.
+	errNegativePosition = errors.New("bzip2: negative position")
+	// errInvalidWhence occurs when seeking with an invalid whence.
+	errInvalidWhence = errors.New("bzip2: invalid whence")
+)
+
+// IndexEntry locates a block in bzip2 data.
+type IndexEntry struct {
+	// Offset is the bit offset of the block from the beginning
+	// of the compressed data, pointing at the block magic.
+	Offset int64
+	// DataOffset is the offset of the blocks data from the
+	// beginning of the uncompressed data.
+	DataOffset int64
+	// Size is the number of uncompressed bytes in the block.
+	Size int64
+	// CRC is the blocks crc.
+	CRC uint32
+}
+
+// Index locates the blocks in bzip2 data, so the uncompressed
+// data can be read from any offset by only decoding the blocks
+// required. Blocks from concatenated streams are included as if
+// they're from a single stream.
+type Index struct {
+	// Level is the largest compression level of the streams,
+	// which limits the size of the blocks. If zero the largest
+	// level possible is used.
+	Level int
+	// Blocks contains the blocks in the order they're found.
+	Blocks []IndexEntry
+}
+
+// BuildIndex creates an index by reading and verifying all
+// the bzip2 data from r.
+func BuildIndex(r io.Reader) (*Index, error) {
+	report, err := Verify(r)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &Index{Level: report.Level}
+	for _, block := range report.Blocks {
+		index.add(block.Offset, int64(block.Size), block.CRC)
+	}
+
+	return index, nil
+}
+
+// Size gets the number of uncompressed bytes in the data.
+func (idx *Index) Size() int64 {
+	if len(idx.Blocks) == 0 {
+		return 0
+	}
+
+	last := idx.Blocks[len(idx.Blocks)-1]
+	return last.DataOffset + last.Size
+}
+
+// add adds a block following the last block in the index.
+func (idx *Index) add(offset, size int64, crc uint32) {
+	idx.Blocks = append(idx.Blocks, IndexEntry{
+		Offset:     offset,
+		DataOffset: idx.Size(),
+		Size:       size,
+		CRC:        crc,
+	})
+}
+
+// blockSize gets the size limit for the blocks.
+func (idx *Index) blockSize() int {
+	if idx.Level < BestSpeed || idx.Level > BestCompression {
+		return BestCompression * baseBlockSize
+	}
+
+	return idx.Level * baseBlockSize
+}
+
+// find gets the index of the block containing the
+// uncompressed data offset given.
+func (idx *Index) find(offset int64) int {
+	return sort.Search(len(idx.Blocks), func(i int) bool {
+		block := idx.Blocks[i]
+		return block.DataOffset+block.Size > offset
+	})
+}
+
+// SeekableReader reads uncompressed data from bzip2 data at any
+// offset, using an Index to decode only the blocks required.
+// Each block is checked against its crc, but the crc of the
+// streams the blocks are in are not.
+type SeekableReader struct {
+	r      io.ReaderAt
+	index  *Index
+	offset int64
+
+	mu    sync.Mutex
+	block int
+	data  []byte
+}
+
+// NewSeekableReader returns a new SeekableReader reading
+// the bzip2 data from r, with the blocks located by index.
+func NewSeekableReader(r io.ReaderAt, index *Index) *SeekableReader {
+	return &SeekableReader{r: r, index: index, block: -1}
+}
+
+// Read reads uncompressed data from the current offset.
+func (sr *SeekableReader) Read(p []byte) (int, error) {
+	n, err := sr.ReadAt(p, sr.offset)
+	sr.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek sets the offset for the next Read, interpreted
+// according to whence like io.Seeker.
+func (sr *SeekableReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += sr.offset
+	case io.SeekEnd:
+		offset += sr.index.Size()
+	default:
+		return 0, errInvalidWhence
+	}
+	if offset < 0 {
+		return 0, errNegativePosition
+	}
+
+	sr.offset = offset
+	return offset, nil
+}
+
+// ReadAt reads uncompressed data beginning at the offset given,
+// it's safe to use concurrently. The most recently decoded block
+// is kept so small sequential reads don't decode blocks again.
+func (sr *SeekableReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativePosition
+	}
+
+	n := 0
+	for n < len(p) {
+		i := sr.index.find(off)
+		if i == len(sr.index.Blocks) {
+			return n, io.EOF
+		}
+
+		data, err := sr.blockData(i)
+		if err != nil {
+			return n, err
+		}
+
+		nn := copy(p[n:], data[off-sr.index.Blocks[i].DataOffset:])
+		n += nn
+		off += int64(nn)
+	}
+
+	return n, nil
+}
+
+// blockData gets the uncompressed data for the block at
+// the index given, decoding it if it isn't kept.
+func (sr *SeekableReader) blockData(i int) ([]byte, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if sr.block == i {
+		return sr.data, nil
+	}
+
+	data, err := readIndexedBlock(sr.r, sr.index.Blocks[i], sr.index.blockSize())
+	if err != nil {
+		return nil, err
+	}
+
+	sr.block = i
+	sr.data = data
+	return data, nil
+}
+
+// readIndexedBlock reads the block located by the index entry
+// from r, checking it matches the entry. The data decoded from
+// the block can't exceed size bytes.
+func readIndexedBlock(r io.ReaderAt, entry IndexEntry, size int) ([]byte, error) {
+	br := bits.NewReaderAt(r, entry.Offset)
+	if br.ReadBits(magicLen) != blockMagic {
+		if br.Err() != nil {
+			return nil, bitsErr(br)
+		}
+
+		return nil, StructuralError("bad magic value")
+	}
+
+	data, crc, err := readBlock(br, size)
+	if err != nil {
+		return nil, err
+	}
+	if crc != entry.CRC || int64(len(data)) != entry.Size {
+		return nil, StructuralError("block doesn't match index")
+	}
+
+	return data, nil
+}
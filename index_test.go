@@ -0,0 +1,258 @@
+package bzip2
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestBuildIndex(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := BuildIndex(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index.Blocks) != 3 {
+		t.Fatal("Incorrect number of blocks. Got", len(index.Blocks), "wanted 3")
+	}
+	if index.Blocks[0].Offset != 32 || index.Blocks[0].DataOffset != 0 {
+		t.Error("First block is incorrect. Got", index.Blocks[0])
+	}
+	if index.Size() != int64(len(linesData())) {
+		t.Error("Index size incorrect. Got", index.Size(), "wanted", len(linesData()))
+	}
+}
+
+func TestBuildIndexEmpty(t *testing.T) {
+	var compressed bytes.Buffer
+	err := NewWriter(&compressed).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := BuildIndex(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Level != 6 || len(index.Blocks) != 0 {
+		t.Fatal("Index should have the streams level. Got", index.Level, "with",
+			len(index.Blocks), "blocks")
+	}
+
+	var buf bytes.Buffer
+	_, err = index.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadIndex(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Level != 6 || len(read.Blocks) != 0 {
+		t.Error("Read index is incorrect. Got", read.Level, "with", len(read.Blocks), "blocks")
+	}
+}
+
+func TestWriterIndex(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		var buf bytes.Buffer
+		writer, err := NewWriterOptions(&buf, &WriterOptions{
+			Level:       BestSpeed,
+			Concurrency: concurrency,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = writer.Write(linesData())
+		if err == nil {
+			err = writer.NewStream()
+		}
+		if err == nil {
+			_, err = writer.Write([]byte("banana"))
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, err := BuildIndex(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(writer.Index(), expected) {
+			t.Error("Writer index doesn't match the built index with concurrency",
+				concurrency, "Got", writer.Index(), "wanted", expected)
+		}
+	}
+}
+
+func TestSeekableReaderReadAt(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := linesData()
+
+	index, err := BuildIndex(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := NewSeekableReader(bytes.NewReader(compressed), index)
+
+	rand.Seed(1)
+	for i := 0; i < 50; i++ {
+		off := rand.Intn(len(expected))
+		p := make([]byte, rand.Intn(len(expected)/2))
+
+		n, err := reader.ReadAt(p, int64(off))
+		if off+len(p) > len(expected) {
+			if err != io.EOF || n != len(expected)-off {
+				t.Fatal("Reading past the end should return io.EOF. Got", n, err)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(p[:n], expected[off:off+n]) {
+			t.Fatal("Data read at", off, "is incorrect")
+		}
+	}
+}
+
+func TestSeekableReaderSeek(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := linesData()
+
+	index, err := BuildIndex(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := NewSeekableReader(bytes.NewReader(compressed), index)
+
+	offset, err := reader.Seek(-100, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != int64(len(expected)-100) {
+		t.Error("Seek offset incorrect. Got", offset)
+	}
+
+	out, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, expected[len(expected)-100:]) {
+		t.Error("Data read after seeking is incorrect")
+	}
+
+	_, err = reader.Seek(-1, io.SeekStart)
+	if err == nil {
+		t.Error("Seeking before the start should fail")
+	}
+}
+
+func TestSeekableReaderCorrupt(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := BuildIndex(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Blocks[1].CRC ^= 1
+
+	reader := NewSeekableReader(bytes.NewReader(compressed), index)
+	_, err = reader.ReadAt(make([]byte, 10), index.Blocks[1].DataOffset)
+	if _, ok := err.(StructuralError); !ok {
+		t.Error("Block not matching the index should return a StructuralError. Got", err)
+	}
+}
+
+func TestIndexFile(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := BuildIndex(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := index.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(indexHeaderLen+len(index.Blocks)*indexEntryLen+4) {
+		t.Error("Incorrect number of bytes written. Got", n)
+	}
+
+	read, err := ReadIndex(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, index) {
+		t.Error("Index read doesn't match. Got", read, "wanted", index)
+	}
+
+	err = read.Verify(bytes.NewReader(compressed))
+	if err != nil {
+		t.Error("Index should verify. Got", err)
+	}
+}
+
+func TestIndexFileCorrupt(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := BuildIndex(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	_, err = index.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	data[indexHeaderLen+20] ^= 0xff
+	_, err = ReadIndex(bytes.NewReader(data))
+	if err != ErrIndex {
+		t.Error("Corrupt index should return ErrIndex. Got", err)
+	}
+
+	_, err = ReadIndex(bytes.NewReader(data[:len(data)-1]))
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Truncated index should return io.ErrUnexpectedEOF. Got", err)
+	}
+
+	// A block crc not matching the data fails verification.
+	index.Blocks[2].CRC ^= 1
+	err = index.Verify(bytes.NewReader(compressed))
+	if err == nil {
+		t.Error("Index with an incorrect crc shouldn't verify")
+	}
+}
@@ -0,0 +1,153 @@
+package bzip2
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/larzconwell/bzip2/internal/crc32"
+)
+
+// An index file stores an Index so a single scan of bzip2 data can
+// be reused, conventionally it's named after the compressed file
+// with ".idx" appended. All values are big-endian:
+//
+//	magic        4 bytes, "BZIX"
+//	version      1 byte, currently 1
+//	level        1 byte, the largest level of the streams, 1-9
+//	count        8 bytes, the number of blocks
+//	count blocks each containing:
+//	  offset       8 bytes, the bit offset of the block magic
+//	  data offset  8 bytes, the offset of the uncompressed data
+//	  size         4 bytes, the number of uncompressed bytes
+//	  crc          4 bytes, the blocks crc
+//	crc          4 bytes, the crc of everything before it
+//
+// The blocks are in order, the data offset of each being the sum of
+// the sizes before it. The crcs are computed the same as bzip2.
+const (
+	// indexMagic is the index file magic number, BZIX.
+	indexMagic = 0x425a4958
+	// indexVersion is the version of the index file format.
+	indexVersion = 1
+	// indexHeaderLen is the number of bytes before the blocks.
+	indexHeaderLen = 14
+	// indexEntryLen is the number of bytes for each block.
+	indexEntryLen = 24
+)
+
+var (
+	// ErrIndex is returned when reading an index
+	// file that's invalid.
+	ErrIndex = errors.New("bzip2: invalid index")
+)
+
+// WriteTo writes the index to w in the index file format.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	if idx.Level < BestSpeed || idx.Level > BestCompression {
+		return 0, fmt.Errorf("bzip2: invalid index level: %d", idx.Level)
+	}
+
+	buf := make([]byte, indexHeaderLen, indexHeaderLen+len(idx.Blocks)*indexEntryLen+4)
+	binary.BigEndian.PutUint32(buf, indexMagic)
+	buf[4] = indexVersion
+	buf[5] = byte(idx.Level)
+	binary.BigEndian.PutUint64(buf[6:], uint64(len(idx.Blocks)))
+
+	entry := make([]byte, indexEntryLen)
+	for _, block := range idx.Blocks {
+		binary.BigEndian.PutUint64(entry, uint64(block.Offset))
+		binary.BigEndian.PutUint64(entry[8:], uint64(block.DataOffset))
+		binary.BigEndian.PutUint32(entry[16:], uint32(block.Size))
+		binary.BigEndian.PutUint32(entry[20:], block.CRC)
+		buf = append(buf, entry...)
+	}
+
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.Update(0, buf))
+	buf = append(buf, crc...)
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadIndex reads an index in the index file format from r,
+// checking the index is consistent. The blocks it locates
+// can be checked against the bzip2 data with Index.Verify.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, indexHeaderLen)
+	_, err := io.ReadFull(br, header)
+	if err != nil {
+		return nil, indexErr(err)
+	}
+	if binary.BigEndian.Uint32(header) != indexMagic {
+		return nil, ErrIndex
+	}
+	if header[4] != indexVersion {
+		return nil, fmt.Errorf("bzip2: unsupported index version: %d", header[4])
+	}
+
+	index := &Index{Level: int(header[5])}
+	if index.Level < BestSpeed || index.Level > BestCompression {
+		return nil, ErrIndex
+	}
+	crc := crc32.Update(0, header)
+
+	count := binary.BigEndian.Uint64(header[6:])
+	entry := make([]byte, indexEntryLen)
+	for i := uint64(0); i < count; i++ {
+		_, err = io.ReadFull(br, entry)
+		if err != nil {
+			return nil, indexErr(err)
+		}
+		crc = crc32.Update(crc, entry)
+
+		offset := int64(binary.BigEndian.Uint64(entry))
+		dataOffset := int64(binary.BigEndian.Uint64(entry[8:]))
+		if dataOffset != index.Size() || (i > 0 &&
+			offset <= index.Blocks[i-1].Offset) {
+			return nil, ErrIndex
+		}
+
+		index.add(offset, int64(binary.BigEndian.Uint32(entry[16:])),
+			binary.BigEndian.Uint32(entry[20:]))
+	}
+
+	_, err = io.ReadFull(br, entry[:4])
+	if err != nil {
+		return nil, indexErr(err)
+	}
+	if binary.BigEndian.Uint32(entry) != crc {
+		return nil, ErrIndex
+	}
+
+	return index, nil
+}
+
+// Verify checks each block located by the index can be decoded
+// from the bzip2 data in r, matching the blocks size and crc. The
+// first error found is returned.
+func (idx *Index) Verify(r io.ReaderAt) error {
+	for _, block := range idx.Blocks {
+		_, err := readIndexedBlock(r, block, idx.blockSize())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexErr gets the error for reading an index file, the
+// end of the data is unexpected so io.EOF isn't returned.
+func indexErr(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return err
+}
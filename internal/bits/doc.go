@@ -1,2 +0,0 @@
-// Package bits provides access to read and write values bit-by-bit.
-package bits
@@ -1,2 +0,0 @@
-// Package bwt implements the Burrows-Wheeler Transform.
-package bwt
@@ -1,7 +1,56 @@
 package huffman
 
+import (
+	"errors"
+)
+
+// MaxCodeLen is the maximum length a code can be.
+const MaxCodeLen = 20
+
+var (
+	// ErrInvalidCodeLens occurs when code-lengths given
+	// can't produce a valid set of codes.
+	ErrInvalidCodeLens = errors.New("huffman: Invalid code-lengths")
+)
+
 // Code contains the bit code for a symbol.
 type Code struct {
 	Len  int
 	Bits uint64
 }
+
+// CanonicalCodes assigns the canonical codes for the code-lengths
+// given, the index being the symbol. Codes are assigned in order of
+// length and then symbol, so only the code-lengths are needed to
+// get the same codes when encoding and decoding.
+func CanonicalCodes(lens []int) ([]*Code, error) {
+	codes := make([]*Code, len(lens))
+	maxLen := 0
+	for _, l := range lens {
+		if l < 1 || l > MaxCodeLen {
+			return nil, ErrInvalidCodeLens
+		}
+
+		if l > maxLen {
+			maxLen = l
+		}
+	}
+
+	bits := uint64(0)
+	for l := 1; l <= maxLen; l++ {
+		for sym, symlen := range lens {
+			if symlen == l {
+				codes[sym] = &Code{Len: l, Bits: bits}
+				bits++
+			}
+		}
+
+		// More codes than can fit in the length.
+		if bits > 1<<uint(l) {
+			return nil, ErrInvalidCodeLens
+		}
+		bits <<= 1
+	}
+
+	return codes, nil
+}
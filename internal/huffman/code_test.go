@@ -0,0 +1,31 @@
+package huffman
+
+import (
+	"testing"
+)
+
+func TestCanonicalCodes(t *testing.T) {
+	codes, err := CanonicalCodes([]int{2, 1, 3, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Code{{2, 2}, {1, 0}, {3, 6}, {3, 7}}
+	for i, code := range codes {
+		if *code != expected[i] {
+			t.Error("Code for", i, "is incorrect. Got", *code, "wanted", expected[i])
+		}
+	}
+}
+
+func TestCanonicalCodesInvalid(t *testing.T) {
+	_, err := CanonicalCodes([]int{1, 2, 2, 2})
+	if err != ErrInvalidCodeLens {
+		t.Error("Oversubscribed code-lengths should be invalid")
+	}
+
+	_, err = CanonicalCodes([]int{0, 1})
+	if err != ErrInvalidCodeLens {
+		t.Error("Zero code-lengths should be invalid")
+	}
+}
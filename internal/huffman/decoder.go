@@ -0,0 +1,82 @@
+package huffman
+
+import (
+	"errors"
+
+	"github.com/larzconwell/bzip2/bits"
+)
+
+var (
+	// ErrInvalidCode occurs when the bits read don't
+	// match any code.
+	ErrInvalidCode = errors.New("huffman: Invalid code")
+)
+
+// Decoder decodes symbols using the canonical codes for a
+// set of code-lengths, the codes are assigned in order of
+// length and then symbol.
+type Decoder struct {
+	// firsts, counts and offsets are indexed by code-length giving
+	// the first code, the number of codes and the index in symbols
+	// of the first symbol for the length.
+	firsts  [MaxCodeLen + 1]int
+	counts  [MaxCodeLen + 1]int
+	offsets [MaxCodeLen + 1]int
+	symbols []uint16
+	maxLen  int
+}
+
+// NewDecoder creates a decoder for the code-lengths given,
+// the index being the symbol.
+func NewDecoder(lens []int) (*Decoder, error) {
+	codes, err := CanonicalCodes(lens)
+	if err != nil {
+		return nil, err
+	}
+	d := &Decoder{symbols: make([]uint16, 0, len(lens))}
+
+	for _, code := range codes {
+		d.counts[code.Len]++
+		if code.Len > d.maxLen {
+			d.maxLen = code.Len
+		}
+	}
+
+	for l := 1; l <= d.maxLen; l++ {
+		d.offsets[l] = len(d.symbols)
+
+		for sym, code := range codes {
+			if code.Len != l {
+				continue
+			}
+
+			if d.offsets[l] == len(d.symbols) {
+				d.firsts[l] = int(code.Bits)
+			}
+			d.symbols = append(d.symbols, uint16(sym))
+		}
+	}
+
+	return d, nil
+}
+
+// Decode reads a single code from br returning its symbol.
+func (d *Decoder) Decode(br *bits.Reader) (uint16, error) {
+	code := 0
+	for l := 1; l <= d.maxLen; l++ {
+		code <<= 1
+		if br.ReadBit() {
+			code |= 1
+		}
+		if br.Err() != nil {
+			return 0, br.Err()
+		}
+
+		idx := code - d.firsts[l]
+		if idx < d.counts[l] {
+			return d.symbols[d.offsets[l]+idx], nil
+		}
+	}
+
+	return 0, ErrInvalidCode
+}
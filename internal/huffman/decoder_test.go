@@ -0,0 +1,53 @@
+package huffman
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/larzconwell/bzip2/bits"
+)
+
+func TestDecoderDecode(t *testing.T) {
+	decoder, err := NewDecoder([]int{2, 1, 3, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Canonical codes are 0: 10, 1: 0, 2: 110, 3: 111.
+	var buf bytes.Buffer
+	bw := bits.NewWriter(&buf)
+	bw.WriteBits(2, 2)
+	bw.WriteBits(3, 7)
+	bw.WriteBits(1, 0)
+	bw.WriteBits(3, 6)
+	bw.WriteBits(7, 0)
+	err = bw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	br := bits.NewReader(&buf)
+	expected := []uint16{0, 3, 1, 2}
+	for _, e := range expected {
+		actual, err := decoder.Decode(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != e {
+			t.Error("Decoded symbol is incorrect. Got", actual, "wanted", e)
+		}
+	}
+}
+
+func TestDecoderInvalidCodeLens(t *testing.T) {
+	_, err := NewDecoder([]int{1, 1, 1})
+	if err != ErrInvalidCodeLens {
+		t.Error("Oversubscribed code-lengths should be invalid")
+	}
+
+	_, err = NewDecoder([]int{1, MaxCodeLen + 1})
+	if err != ErrInvalidCodeLens {
+		t.Error("Code-lengths longer than the max should be invalid")
+	}
+}
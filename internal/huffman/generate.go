@@ -4,40 +4,281 @@ import (
 	"github.com/larzconwell/bzip2/internal/rle2"
 )
 
-// TreeSelectionLimit is the symbol limit for each tree selection.
-const TreeSelectionLimit = 50
+const (
+	// TreeSelectionLimit is the symbol limit for each tree selection.
+	TreeSelectionLimit = 50
+
+	// minTrees and maxTrees are the number of trees a block can use.
+	minTrees = 2
+	maxTrees = 6
+
+	// treeIterations is the number of times the trees are refined,
+	// extremeIterations being used for extreme compression.
+	treeIterations    = 4
+	extremeIterations = 10
+
+	// lesserCost and greaterCost are the initial code-lengths used
+	// for symbols inside and outside of a trees initial range.
+	lesserCost  = 0
+	greaterCost = 15
+)
 
 // GenerateTrees creates the trees required to encode the data, and
 // which tree to use for each 50 symbol block of data in src.
-func GenerateTrees(freqs rle2.Frequencies, src []uint16) ([]*Tree, []int) {
+//
+// The symbols are initially split into ranges with similar total
+// frequencies, one for each tree. Each 50 symbol block is then
+// assigned the tree that encodes it with the fewest bits, and the
+// trees are rebuilt from the frequencies of the blocks they were
+// assigned, repeating to refine the trees.
+//
+// Trees are generated for each number of trees up to one per
+// selection, keeping those with the lowest cost in bits including
+// the code-lengths and selections written with them. If extreme is
+// set the trees are also generated from an initial split of src
+// into contiguous runs of selections, and refined more times.
+func GenerateTrees(freqs rle2.Frequencies, src []uint16, extreme bool) ([]*Tree, []int) {
 	// Get the number of huffman tree selections.
 	numSelections := (len(src) + TreeSelectionLimit - 1) / TreeSelectionLimit
 
-	// Get the number of trees to use.
-	numTrees := 2
-	if numSelections > 6 {
-		numTrees = 6
-	} else if numSelections > 2 {
-		numTrees = numSelections
+	// Get the most trees worth trying.
+	limit := minTrees
+	if numSelections > maxTrees {
+		limit = maxTrees
+	} else if numSelections > minTrees {
+		limit = numSelections
+	}
+
+	iterations := treeIterations
+	if extreme {
+		iterations = extremeIterations
+	}
+
+	var best *generated
+	for numTrees := minTrees; numTrees <= limit; numTrees++ {
+		attempts := []*generated{
+			refineTrees(freqs, src, initialCodeLens(freqs, numTrees), iterations),
+		}
+		if extreme {
+			attempts = append(attempts, refineTrees(freqs, src,
+				contiguousCodeLens(freqs, src, numTrees), iterations))
+		}
+
+		for _, attempt := range attempts {
+			if best == nil || attempt.cost < best.cost {
+				best = attempt
+			}
+		}
+	}
+
+	return best.trees, best.selections
+}
+
+// generated is a set of trees and selections, with
+// the number of bits it takes to encode the data.
+type generated struct {
+	trees      []*Tree
+	selections []int
+	cost       int
+}
+
+// refineTrees assigns each 50 symbol block of src the tree with
+// the lowest cost using the code-lengths given, rebuilding the
+// trees from the assigned blocks the number of times given. The
+// trees from the iteration with the lowest cost are returned.
+func refineTrees(freqs rle2.Frequencies, src []uint16, lens [][]int, iterations int) *generated {
+	numSelections := (len(src) + TreeSelectionLimit - 1) / TreeSelectionLimit
+	var best *generated
+
+	for iter := 0; iter < iterations; iter++ {
+		trees := make([]*Tree, len(lens))
+		selections := make([]int, numSelections)
+		treeFreqs := make([]rle2.Frequencies, len(lens))
+		for i := range treeFreqs {
+			treeFreqs[i] = make(rle2.Frequencies, len(freqs))
+		}
+
+		// Assign each 50 symbol block the tree with the lowest cost.
+		for i := range selections {
+			group := selectionGroup(src, i)
+
+			selection := 0
+			lowestCost := 0
+			for j, treeLens := range lens {
+				cost := 0
+				for _, v := range group {
+					cost += treeLens[v]
+				}
+
+				if j == 0 || cost < lowestCost {
+					selection = j
+					lowestCost = cost
+				}
+			}
+
+			selections[i] = selection
+			for _, v := range group {
+				treeFreqs[selection][v]++
+			}
+		}
+
+		// Rebuild the trees from the blocks assigned to them.
+		for i := range trees {
+			trees[i] = NewTree(nonZeroFrequencies(treeFreqs[i]))
+			for sym, code := range trees[i].Codes {
+				lens[i][sym] = code.Len
+			}
+		}
+
+		cost := treesCost(trees, treeFreqs, selections)
+		if best == nil || cost < best.cost {
+			best = &generated{trees: trees, selections: selections, cost: cost}
+		}
+	}
+
+	return best
+}
+
+// selectionGroup gets the symbols in src for the selection given.
+func selectionGroup(src []uint16, selection int) []uint16 {
+	start := selection * TreeSelectionLimit
+	end := start + TreeSelectionLimit
+	if end > len(src) {
+		end = len(src)
+	}
+
+	return src[start:end]
+}
+
+// treesCost gets the number of bits used to encode the data with the
+// trees and selections, matching how they're written in a block. The
+// code-lengths are delta encoded and the selections move-to-front
+// encoded in unary, with treeFreqs giving the symbols encoded with
+// each tree.
+func treesCost(trees []*Tree, treeFreqs []rle2.Frequencies, selections []int) int {
+	cost := 0
+	for i, tree := range trees {
+		codelen := MaxCodeLen
+		for _, code := range tree.Codes {
+			if code.Len < codelen {
+				codelen = code.Len
+			}
+		}
+		cost += 5
+
+		for sym, code := range tree.Codes {
+			delta := code.Len - codelen
+			if delta < 0 {
+				delta = -delta
+			}
+			codelen = code.Len
+
+			cost += 2*delta + 1 + treeFreqs[i][sym]*code.Len
+		}
+	}
+
+	order := make([]int, len(trees))
+	for i := range order {
+		order[i] = i
+	}
+	for _, selection := range selections {
+		pos := 0
+		for order[pos] != selection {
+			pos++
+		}
+		copy(order[1:pos+1], order[:pos])
+		order[0] = selection
+
+		cost += pos + 1
+	}
+
+	return cost
+}
+
+// contiguousCodeLens gets code-lengths for initial tree assignments
+// by splitting the selections into contiguous runs, one for each
+// tree, and building a tree from the symbols in each run.
+func contiguousCodeLens(freqs rle2.Frequencies, src []uint16, numTrees int) [][]int {
+	numSelections := (len(src) + TreeSelectionLimit - 1) / TreeSelectionLimit
+	treeFreqs := make([]rle2.Frequencies, numTrees)
+	for i := range treeFreqs {
+		treeFreqs[i] = make(rle2.Frequencies, len(freqs))
+	}
+
+	for i := 0; i < numSelections; i++ {
+		tree := i * numTrees / numSelections
+		for _, v := range selectionGroup(src, i) {
+			treeFreqs[tree][v]++
+		}
 	}
 
-	// Create the huffman trees generating the codes for the frequencies.
-	trees := make([]*Tree, numTrees)
-	for i := range trees {
-		trees[i] = NewTree(freqs)
+	lens := make([][]int, numTrees)
+	for i := range lens {
+		lens[i] = make([]int, len(freqs))
+		for sym, code := range NewTree(nonZeroFrequencies(treeFreqs[i])).Codes {
+			lens[i][sym] = code.Len
+		}
 	}
 
-	// Get the tree selection to use for each 50 symbol block.
-	idx := 0
-	selections := make([]int, numSelections)
-	for i := range selections {
-		selections[i] = idx
+	return lens
+}
+
+// initialCodeLens gets the code-lengths used to make the first tree
+// assignments. The symbols are split into a range for each tree
+// with each range having a similar total frequency, symbols in
+// the range being cheap for the tree and the rest expensive.
+func initialCodeLens(freqs rle2.Frequencies, numTrees int) [][]int {
+	lens := make([][]int, numTrees)
+
+	remaining := 0
+	for _, f := range freqs {
+		remaining += f
+	}
+
+	start := 0
+	for parts := numTrees; parts > 0; parts-- {
+		target := remaining / parts
+		end := start - 1
+		total := 0
+		for total < target && end < len(freqs)-1 {
+			end++
+			total += freqs[end]
+		}
+
+		// Alternate between including and excluding the symbol
+		// that reached the target, so the ranges stay balanced.
+		if end > start && parts != numTrees && parts != 1 &&
+			(numTrees-parts)%2 == 1 {
+			total -= freqs[end]
+			end--
+		}
+
+		treeLens := make([]int, len(freqs))
+		for sym := range treeLens {
+			treeLens[sym] = greaterCost
+			if sym >= start && sym <= end {
+				treeLens[sym] = lesserCost
+			}
+		}
+		lens[parts-1] = treeLens
+
+		start = end + 1
+		remaining -= total
+	}
+
+	return lens
+}
 
-		idx++
-		if idx == numTrees {
-			idx = 0
+// nonZeroFrequencies gets the frequencies with every symbol used
+// at least once, so unused symbols still get reasonable codes.
+func nonZeroFrequencies(freqs rle2.Frequencies) rle2.Frequencies {
+	nonZero := make(rle2.Frequencies, len(freqs))
+	for i, f := range freqs {
+		nonZero[i] = f
+		if f == 0 {
+			nonZero[i] = 1
 		}
 	}
 
-	return trees, selections
+	return nonZero
 }
@@ -12,7 +12,7 @@ func TestGenerateTreesLowest(t *testing.T) {
 	data := []uint16{'\x03', '\x00', '\x03', '\x00', '\x01', '\x04'}
 	freqs := rle2.GetFrequencies(reduced, data)
 
-	trees, selections := GenerateTrees(freqs, data)
+	trees, selections := GenerateTrees(freqs, data, false)
 	if len(trees) < 2 {
 		t.Error("Not enough huffman trees generated")
 	}
@@ -34,7 +34,7 @@ func TestGenerateTreesMultipleSelections(t *testing.T) {
 	}
 	freqs := rle2.GetFrequencies(reduced, data)
 
-	trees, selections := GenerateTrees(freqs, data)
+	trees, selections := GenerateTrees(freqs, data, false)
 	if len(trees) < 2 {
 		t.Error("Not enough huffman trees generated")
 	}
@@ -46,3 +46,102 @@ func TestGenerateTreesMultipleSelections(t *testing.T) {
 		t.Error("The wrong number of huffman tree selections was returned")
 	}
 }
+
+func TestGenerateTreesHeterogeneous(t *testing.T) {
+	_, reduced := symbols.Get([]byte("banana"))
+	data := make([]uint16, 0, 1001)
+	for i := 0; i < 500; i++ {
+		data = append(data, uint16(i%2))
+	}
+	for i := 0; i < 500; i++ {
+		data = append(data, uint16(2+i%2))
+	}
+	data = append(data, '\x04')
+	freqs := rle2.GetFrequencies(reduced, data)
+
+	trees, selections := GenerateTrees(freqs, data, false)
+	first := trees[selections[0]]
+	last := trees[selections[len(selections)-1]]
+	if first == last {
+		t.Fatal("Different data should use different huffman trees")
+	}
+
+	if first.Codes[0].Len >= last.Codes[0].Len {
+		t.Error("The first tree should favor the symbols at the start")
+	}
+	if last.Codes[2].Len >= first.Codes[2].Len {
+		t.Error("The last tree should favor the symbols at the end")
+	}
+}
+
+// heterogeneousData returns data that switches between
+// symbols, with its frequencies.
+func heterogeneousData() (rle2.Frequencies, []uint16) {
+	_, reduced := symbols.Get([]byte("banana"))
+	data := make([]uint16, 0, 2001)
+	for i := 0; i < 2000; i++ {
+		data = append(data, uint16((i/150)%3+i%2))
+	}
+	data = append(data, '\x04')
+
+	return rle2.GetFrequencies(reduced, data), data
+}
+
+// generatedCost gets the cost of the trees and selections.
+func generatedCost(freqs rle2.Frequencies, data []uint16, trees []*Tree, selections []int) int {
+	treeFreqs := make([]rle2.Frequencies, len(trees))
+	for i := range treeFreqs {
+		treeFreqs[i] = make(rle2.Frequencies, len(freqs))
+	}
+	for i, selection := range selections {
+		for _, v := range selectionGroup(data, i) {
+			treeFreqs[selection][v]++
+		}
+	}
+
+	return treesCost(trees, treeFreqs, selections)
+}
+
+func TestGenerateTreesCost(t *testing.T) {
+	freqs, data := heterogeneousData()
+	trees, selections := GenerateTrees(freqs, data, false)
+	cost := generatedCost(freqs, data, trees, selections)
+
+	for numTrees := minTrees; numTrees <= maxTrees; numTrees++ {
+		attempt := refineTrees(freqs, data, initialCodeLens(freqs, numTrees), treeIterations)
+		if attempt.cost < cost {
+			t.Error("Trees with the lowest cost should be used. Got", cost,
+				"but", numTrees, "trees cost", attempt.cost)
+		}
+	}
+}
+
+func TestGenerateTreesExtreme(t *testing.T) {
+	freqs, data := heterogeneousData()
+	trees, selections := GenerateTrees(freqs, data, false)
+	extremeTrees, extremeSelections := GenerateTrees(freqs, data, true)
+
+	cost := generatedCost(freqs, data, trees, selections)
+	extremeCost := generatedCost(freqs, data, extremeTrees, extremeSelections)
+	if extremeCost > cost {
+		t.Error("Extreme trees shouldn't cost more. Got", extremeCost, "wanted at most", cost)
+	}
+}
+
+func TestTreesCost(t *testing.T) {
+	trees := []*Tree{
+		NewTree(rle2.Frequencies{1, 1, 2}),
+		NewTree(rle2.Frequencies{4, 1, 1}),
+	}
+	treeFreqs := []rle2.Frequencies{{0, 1, 2}, {3, 1, 0}}
+
+	// Code-lengths are 2, 2, 1 and 1, 2, 2. Each tree costs 5 bits for the
+	// initial length and 3 bits for the symbols, with 2 bits for each
+	// length change. Selections 1, 0, 1 are all 1 after move-to-front,
+	// costing 2 bits each.
+	expected := (5 + 3 + 4) + (5 + 3 + 2) + (1*2 + 2*1) + (3*1 + 1*2) + 3*2
+	cost := treesCost(trees, treeFreqs, []int{1, 0, 1})
+	if cost != expected {
+		t.Error("Cost is incorrect. Got", cost, "wanted", expected)
+	}
+}
@@ -14,10 +14,38 @@ type Tree struct {
 }
 
 // NewTree creates a huffman tree and gets the codes for the symbol
-// frequencies given.
+// frequencies given. Codes are limited to MaxCodeLen bits, if a
+// code is longer the frequencies are scaled down, flattening the
+// tree, until every code fits.
+//
+// Only the code-lengths are taken from the tree, the codes are
+// assigned with CanonicalCodes so they match the decoder.
 func NewTree(freqs rle2.Frequencies) *Tree {
-	tree := &Tree{Codes: make([]*Code, len(freqs))}
+	tree := &Tree{}
+	lens := make([]int, len(freqs))
 
+	for {
+		tree.root = newRoot(freqs)
+		getCodeLens(tree.root, 0, lens)
+		if maxCodeLen(lens) <= MaxCodeLen {
+			break
+		}
+
+		scaled := make(rle2.Frequencies, len(freqs))
+		for i, f := range freqs {
+			scaled[i] = 1 + f/2
+		}
+		freqs = scaled
+	}
+
+	// Code-lengths from a tree always produce valid codes.
+	tree.Codes, _ = CanonicalCodes(lens)
+	return tree
+}
+
+// newRoot builds the nodes for the frequencies given,
+// returning the root node.
+func newRoot(freqs rle2.Frequencies) *Node {
 	var queue NodeQueue
 	for i, f := range freqs {
 		queue = append(queue, &Node{Value: uint16(i), Frequency: f})
@@ -37,20 +65,29 @@ func NewTree(freqs rle2.Frequencies) *Tree {
 		})
 	}
 
-	tree.root = heap.Pop(&queue).(*Node)
-	tree.getCodes(tree.root, 0, 0)
-	return tree
+	return heap.Pop(&queue).(*Node)
 }
 
-// getCodes finds the codes for the frequencies.
-// TODO: Codes cannot be longer than 20 bits long
-func (t Tree) getCodes(node *Node, n int, bits uint64) {
+// getCodeLens finds the code-lengths for the frequencies.
+func getCodeLens(node *Node, n int, lens []int) {
 	if node.Leaf() {
-		t.Codes[node.Value] = &Code{Len: n, Bits: bits}
+		lens[node.Value] = n
 		return
 	}
 
 	n++
-	t.getCodes(node.Left, n, (bits<<1)|0)
-	t.getCodes(node.Right, n, (bits<<1)|1)
+	getCodeLens(node.Left, n, lens)
+	getCodeLens(node.Right, n, lens)
+}
+
+// maxCodeLen gets the length of the longest code.
+func maxCodeLen(lens []int) int {
+	max := 0
+	for _, l := range lens {
+		if l > max {
+			max = l
+		}
+	}
+
+	return max
 }
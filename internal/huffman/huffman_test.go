@@ -1,7 +1,12 @@
 package huffman
 
 import (
+	"bytes"
+	"math/rand"
 	"testing"
+	"time"
+
+	"github.com/larzconwell/bzip2/bits"
 
 	"github.com/larzconwell/bzip2/internal/rle2"
 	"github.com/larzconwell/bzip2/internal/symbols"
@@ -24,3 +29,101 @@ func TestTreeCodeLength(t *testing.T) {
 		t.Error("The lowest code-length isn't the most used symbol")
 	}
 }
+
+// fibonacciFrequencies produces frequencies that create the
+// deepest possible tree for the number of symbols.
+func fibonacciFrequencies(n int) rle2.Frequencies {
+	freqs := make(rle2.Frequencies, n)
+	for i := range freqs {
+		freqs[i] = 1
+		if i > 1 {
+			freqs[i] = freqs[i-1] + freqs[i-2]
+		}
+	}
+
+	return freqs
+}
+
+func TestTreeCodeLengthLimit(t *testing.T) {
+	freqs := fibonacciFrequencies(40)
+	tree := NewTree(freqs)
+
+	lens := make([]int, len(tree.Codes))
+	kraft := 0.0
+	for i, code := range tree.Codes {
+		if code.Len > MaxCodeLen {
+			t.Error("Code-length", code.Len, "is longer than the max")
+		}
+
+		lens[i] = code.Len
+		kraft += 1 / float64(uint64(1)<<uint(code.Len))
+	}
+	if kraft > 1 {
+		t.Error("Code-lengths don't form a valid prefix code")
+	}
+
+	_, err := NewDecoder(lens)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTreeCodeLengthLimitMaxSymbols(t *testing.T) {
+	freqs := fibonacciFrequencies(258)
+	for i := 45; i < len(freqs); i++ {
+		freqs[i] = freqs[44]
+	}
+	tree := NewTree(freqs)
+
+	for _, code := range tree.Codes {
+		if code.Len > MaxCodeLen {
+			t.Error("Code-length", code.Len, "is longer than the max")
+		}
+	}
+
+	if tree.Codes[0].Len < tree.Codes[len(freqs)-1].Len {
+		t.Error("The least used symbol has a shorter code than the most used")
+	}
+}
+
+func TestTreeCodesMatchDecoder(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+
+	for size := 3; size <= 258; size++ {
+		data := make([]uint16, 1000)
+		freqs := make(rle2.Frequencies, size)
+		for i := range data {
+			data[i] = uint16(rand.Intn(1 + rand.Intn(size)))
+			freqs[data[i]]++
+		}
+		tree := NewTree(freqs)
+
+		var buf bytes.Buffer
+		bw := bits.NewWriter(&buf)
+		lens := make([]int, len(tree.Codes))
+		for i, code := range tree.Codes {
+			lens[i] = code.Len
+		}
+		for _, v := range data {
+			bw.WriteBits(uint(tree.Codes[v].Len), tree.Codes[v].Bits)
+		}
+		bw.WriteBits(7, 0)
+		bw.Flush()
+
+		decoder, err := NewDecoder(lens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		br := bits.NewReader(&buf)
+		for _, v := range data {
+			actual, err := decoder.Decode(br)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual != v {
+				t.Fatal("Decoded symbol doesn't match for alphabet size", size)
+			}
+		}
+	}
+}
@@ -27,3 +27,21 @@ func Transform(syms symbols.ReducedSet, dst, src []byte) {
 		dst[i] = byte(symidx)
 	}
 }
+
+// InverseTransform reverses the move-to-front transform on the src
+// slice and writes the results to dst. Dst and src may point to the
+// same memory.
+func InverseTransform(syms symbols.ReducedSet, dst, src []byte) {
+	symbols := make(symbols.ReducedSet, len(syms))
+	copy(symbols, syms)
+
+	for i, symidx := range src {
+		b := symbols[symidx]
+
+		// Move the byte b to the front of the set.
+		copy(symbols[1:], symbols[:symidx])
+		symbols[0] = b
+
+		dst[i] = b
+	}
+}
@@ -51,6 +51,33 @@ func TestMTFTransformAfterBWT(t *testing.T) {
 	}
 }
 
+func TestMTFInverseTransform(t *testing.T) {
+	data := []byte("\x01\x01\x02\x01\x01\x01")
+	_, reduced := symbols.Get([]byte("banana"))
+	InverseTransform(reduced, data, data)
+
+	if string(data) != "banana" {
+		t.Error("Output is incorrect")
+	}
+}
+
+func TestMTFInverseTransformRoundTrip(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+
+	src := make([]byte, 10000)
+	data := make([]byte, len(src))
+	for i := range src {
+		src[i] = byte(rand.Intn(256))
+	}
+	_, reduced := symbols.Get(src)
+
+	Transform(reduced, data, src)
+	InverseTransform(reduced, data, data)
+	if string(data) != string(src) {
+		t.Error("Output is incorrect")
+	}
+}
+
 func BenchmarkMTFTransform(b *testing.B) {
 	rand.Seed(time.Now().UnixNano())
 
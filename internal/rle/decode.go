@@ -0,0 +1,31 @@
+package rle
+
+// Decode decodes data encoded by a RunList, reconstructing
+// the original data.
+func Decode(data []byte) []byte {
+	var lastByte byte
+	runlen := 0
+	dst := make([]byte, 0, len(data))
+
+	for _, b := range data {
+		// After 4 repeats the byte is the length of the rest of the run.
+		if runlen == 4 {
+			for i := 0; i < int(b); i++ {
+				dst = append(dst, lastByte)
+			}
+
+			runlen = 0
+			continue
+		}
+
+		if runlen == 0 || b != lastByte {
+			runlen = 0
+		}
+		lastByte = b
+		runlen++
+
+		dst = append(dst, b)
+	}
+
+	return dst
+}
@@ -0,0 +1,36 @@
+package rle
+
+import (
+	"testing"
+
+	"github.com/larzconwell/bzip2/internal/testhelpers"
+)
+
+func TestDecode(t *testing.T) {
+	expected := []byte("banan" + "aaaaaaa" + "bbbbbb" + "anana")
+	actual := Decode([]byte("bananaaaa\x03bbbb\x02anana"))
+	if string(actual) != string(expected) {
+		t.Error("Decoded data is incorrect. Got", string(actual), "wanted",
+			string(expected))
+	}
+}
+
+func TestDecodeZeroLength(t *testing.T) {
+	expected := []byte("aaaab")
+	actual := Decode([]byte("aaaa\x00b"))
+	if string(actual) != string(expected) {
+		t.Error("Decoded data is incorrect. Got", string(actual), "wanted",
+			string(expected))
+	}
+}
+
+func TestDecodeRunList(t *testing.T) {
+	expected := testhelpers.RandomRunData(100000)
+	runlist := NewRunList()
+	runlist.Update(expected)
+
+	actual := Decode(runlist.Encode())
+	if string(actual) != string(expected) {
+		t.Error("Decoded data doesn't match the original data")
+	}
+}
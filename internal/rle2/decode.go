@@ -0,0 +1,56 @@
+package rle2
+
+import (
+	"errors"
+
+	"github.com/larzconwell/bzip2/internal/symbols"
+)
+
+var (
+	// ErrSizeExceeded occurs when the decoded data would
+	// be larger than the size given.
+	ErrSizeExceeded = errors.New("rle2: Decoded size exceeded")
+)
+
+// Decode decodes src which was encoded using Encode, decoding stops
+// at the end of block symbol. The decoded data cannot be larger
+// than size bytes, otherwise ErrSizeExceeded is returned.
+func Decode(syms symbols.ReducedSet, src []uint16, size int) ([]byte, error) {
+	eob := uint16(len(syms) + 1)
+	dst := make([]byte, 0, len(src))
+
+	// Runs are written in bijective base-2, the first symbols
+	// being the least significant.
+	repeat := 0
+	weight := 1
+	for _, v := range src {
+		if v <= '\x01' {
+			repeat += weight << v
+			weight <<= 1
+			if repeat > size {
+				return nil, ErrSizeExceeded
+			}
+
+			continue
+		}
+
+		if len(dst)+repeat > size {
+			return nil, ErrSizeExceeded
+		}
+		for ; repeat > 0; repeat-- {
+			dst = append(dst, '\x00')
+		}
+		weight = 1
+
+		if v == eob {
+			break
+		}
+		if len(dst) == size {
+			return nil, ErrSizeExceeded
+		}
+
+		dst = append(dst, byte(v-1))
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,55 @@
+package rle2
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/larzconwell/bzip2/internal/symbols"
+)
+
+func TestRL2Decode(t *testing.T) {
+	src := []uint16{'\x03', '\x00', '\x03', '\x03', '\x00', '\x01', '\x04'}
+	expected := []byte("\x02\x00\x02\x02\x00\x00\x00\x00\x00")
+
+	_, reduced := symbols.Get([]byte("banana"))
+	dst, err := Decode(reduced, src, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(dst) != string(expected) {
+		t.Error("Decoded data doesn't match expected data")
+	}
+}
+
+func TestRL2DecodeSizeExceeded(t *testing.T) {
+	src := []uint16{'\x03', '\x00', '\x03', '\x03', '\x00', '\x01', '\x04'}
+
+	_, reduced := symbols.Get([]byte("banana"))
+	_, err := Decode(reduced, src, 8)
+	if err != ErrSizeExceeded {
+		t.Error("Decoding past the size should return ErrSizeExceeded. Got", err)
+	}
+}
+
+func TestRL2DecodeRoundTrip(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+
+	src := make([]byte, 100000)
+	for i := range src {
+		if rand.Intn(2) == 1 {
+			src[i] = byte(rand.Intn(256))
+		}
+	}
+	_, reduced := symbols.Get(src)
+
+	dst, err := Decode(reduced, Encode(reduced, src), len(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(dst) != string(src) {
+		t.Error("Decoded data doesn't match the original data")
+	}
+}
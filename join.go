@@ -0,0 +1,213 @@
+package bzip2
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/larzconwell/bzip2/bits"
+)
+
+var (
+	// errJoinLevel occurs when joining streams
+	// with different block sizes.
+	errJoinLevel = errors.New("bzip2: streams have different block sizes")
+)
+
+// Join merges the bzip2 streams read from srcs, including any
+// concatenated streams, into a single stream written to dst without
+// compressing the data again. The bits of each block are copied as
+// they are, only the headers and trailers between the streams are
+// dropped and the combined crc computed again from the block crcs.
+//
+// Every stream must use the same block size. Each block is decoded
+// to find where it ends, so the block and stream crcs are verified
+// as the streams are joined.
+func Join(dst io.Writer, srcs ...io.Reader) error {
+	j := &joiner{bw: bits.NewWriter(dst)}
+	for _, src := range srcs {
+		err := readBlocks(src, j.add)
+		if err != nil {
+			return err
+		}
+	}
+
+	return j.close()
+}
+
+// joiner joins copied blocks into a single stream.
+type joiner struct {
+	bw     *bits.Writer
+	level  int
+	crc    uint32
+	blocks int
+}
+
+// add copies the block to the end of the stream.
+func (j *joiner) add(block *copiedBlock) error {
+	if j.level == 0 {
+		j.writeHeader(block.level)
+	}
+	if block.level != j.level {
+		return errJoinLevel
+	}
+
+	copyBits(j.bw, block.data, block.from, block.to)
+	j.crc = ((j.crc << 1) | (j.crc >> 31)) ^ block.crc
+	j.blocks++
+	return j.bw.Err()
+}
+
+// close writes the stream trailer, without any blocks
+// the default block size is used for the header.
+func (j *joiner) close() error {
+	if j.level == 0 {
+		j.writeHeader(6)
+	}
+
+	j.bw.WriteBits(magicLen, finalMagic)
+	j.bw.WriteBits(32, uint64(j.crc))
+	return j.bw.Close()
+}
+
+// writeHeader writes the file header for the stream.
+func (j *joiner) writeHeader(level int) {
+	j.level = level
+	j.bw.WriteBits(16, fileMagic)
+	j.bw.WriteBits(8, 'h')
+	j.bw.WriteBits(8, uint64('0'+level))
+}
+
+// copiedBlock is a block read by readBlocks, the bytes
+// it's in are kept so its bits can be copied.
+type copiedBlock struct {
+	level int
+	crc   uint32
+	data  []byte
+	from  int64
+	to    int64
+}
+
+// readBlocks reads the blocks from each stream read from r,
+// calling fn with each. The block and stream crcs are verified,
+// a stream crc only after its blocks are given to fn.
+func readBlocks(r io.Reader, fn func(*copiedBlock) error) error {
+	rr := &recordingReader{r: bufio.NewReader(r)}
+	br := bits.NewReader(rr)
+
+	for streams := 0; ; streams++ {
+		// The data can end after any stream but the first.
+		offset := br.Offset()
+		blockSize, err := readFileHeader(br)
+		if err == io.ErrUnexpectedEOF && streams > 0 && br.Offset() == offset {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = readStreamBlocks(br, rr, blockSize, fn)
+		if err != nil {
+			return err
+		}
+		br.Align()
+	}
+}
+
+// readStreamBlocks reads the blocks in a stream after its header.
+func readStreamBlocks(br *bits.Reader, rr *recordingReader, blockSize int,
+	fn func(*copiedBlock) error) error {
+	var crc uint32
+
+	for {
+		// Only the bytes from the current block onward are needed.
+		start := br.Offset()
+		rr.discard(start / 8)
+
+		magic := br.ReadBits(magicLen)
+		if br.Err() != nil {
+			return bitsErr(br)
+		}
+
+		switch magic {
+		case blockMagic:
+			_, blockCRC, err := readBlock(br, blockSize)
+			if err != nil {
+				return err
+			}
+
+			err = fn(&copiedBlock{
+				level: blockSize / baseBlockSize,
+				crc:   blockCRC,
+				data:  rr.data,
+				from:  start - rr.offset*8,
+				to:    br.Offset() - rr.offset*8,
+			})
+			if err != nil {
+				return err
+			}
+			crc = ((crc << 1) | (crc >> 31)) ^ blockCRC
+		case finalMagic:
+			streamCRC := uint32(br.ReadBits(32))
+			if br.Err() != nil {
+				return bitsErr(br)
+			}
+
+			if streamCRC != crc {
+				return ErrChecksum
+			}
+			return nil
+		default:
+			return StructuralError("bad magic value")
+		}
+	}
+}
+
+// recordingReader is an io.Reader that keeps the bytes read,
+// so the bits of a block can be copied after it's decoded.
+// The data begins at the byte offset offset.
+type recordingReader struct {
+	r      *bufio.Reader
+	data   []byte
+	offset int64
+}
+
+// Read reads and keeps bytes into p.
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.data = append(rr.data, p[:n]...)
+
+	return n, err
+}
+
+// ReadByte reads and keeps a single byte.
+func (rr *recordingReader) ReadByte() (byte, error) {
+	b, err := rr.r.ReadByte()
+	if err == nil {
+		rr.data = append(rr.data, b)
+	}
+
+	return b, err
+}
+
+// discard discards the bytes before the byte offset given.
+func (rr *recordingReader) discard(offset int64) {
+	rr.data = rr.data[offset-rr.offset:]
+	rr.offset = offset
+}
+
+// copyBits writes the bits in data between the
+// bit offsets from and to to the bit writer.
+func copyBits(bw *bits.Writer, data []byte, from, to int64) {
+	br := bits.NewReaderAt(bytes.NewReader(data), from)
+	for n := to - from; n > 0; {
+		chunk := uint(56)
+		if n < 56 {
+			chunk = uint(n)
+		}
+
+		bw.WriteBits(chunk, br.ReadBits(chunk))
+		n -= int64(chunk)
+	}
+}
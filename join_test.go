@@ -0,0 +1,118 @@
+package bzip2
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestJoin(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A source with two streams, with blocks ending at different bit alignments.
+	var multistream bytes.Buffer
+	writer, err := NewWriterLevel(&multistream, BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write([]byte("banana"))
+	if err == nil {
+		err = writer.NewStream()
+	}
+	if err == nil {
+		_, err = writer.Write([]byte("apple"))
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var joined bytes.Buffer
+	err = Join(&joined, bytes.NewReader(compressed), &multistream,
+		bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(bytes.NewReader(joined.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Streams != 1 || len(report.Blocks) != 8 {
+		t.Error("Joined data should be a single stream. Got", report.Streams,
+			"streams and", len(report.Blocks), "blocks")
+	}
+
+	out, err := ioutil.ReadAll(bzip2.NewReader(&joined))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := append(append(linesData(), "bananaapple"...), linesData()...)
+	if !bytes.Equal(out, expected) {
+		t.Error("Output of the joined stream is incorrect")
+	}
+}
+
+func TestJoinEmpty(t *testing.T) {
+	var joined bytes.Buffer
+	err := Join(&joined)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadAll(NewReader(&joined))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Error("Output should be empty. Got", len(out), "bytes")
+	}
+}
+
+func TestJoinLevelMismatch(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	_, err = writer.Write([]byte("banana"))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Join(ioutil.Discard, bytes.NewReader(compressed), &buf)
+	if err != errJoinLevel {
+		t.Error("Joining different block sizes should fail. Got", err)
+	}
+}
+
+func TestJoinCorrupt(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed[10] ^= 0xff
+
+	err = Join(ioutil.Discard, bytes.NewReader(compressed))
+	if err != ErrChecksum {
+		t.Error("Corrupt block should return ErrChecksum. Got", err)
+	}
+
+	err = Join(ioutil.Discard, bytes.NewReader(compressed[:4]))
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Data without blocks should return io.ErrUnexpectedEOF. Got", err)
+	}
+}
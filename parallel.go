@@ -0,0 +1,336 @@
+package bzip2
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/larzconwell/bzip2/bits"
+)
+
+// parallelChunkSize is the number of bytes read at a time
+// when blocks are decoded in parallel.
+const parallelChunkSize = 1 << 20
+
+// decodedBlock is the result of decoding a block in
+// the background.
+type decodedBlock struct {
+	data []byte
+	crc  uint32
+	end  int64
+	err  error
+}
+
+// decodeBlockAt decodes the block beginning at the bit offset
+// given in data. The bit offset in data the block ended at
+// is included.
+func decodeBlockAt(data []byte, offset int64, size int) *decodedBlock {
+	br := bits.NewReaderAt(bytes.NewReader(data), offset)
+
+	if br.ReadBits(magicLen) != blockMagic {
+		if br.Err() != nil {
+			return &decodedBlock{err: bitsErr(br)}
+		}
+
+		return &decodedBlock{err: StructuralError("bad magic value")}
+	}
+
+	decoded, crc, err := readBlock(br, size)
+	return &decodedBlock{data: decoded, crc: crc, end: br.Offset(), err: err}
+}
+
+// blockJob is a block being decoded in the background.
+type blockJob struct {
+	start   int64
+	size    int
+	decoded chan *decodedBlock
+}
+
+// parallelReader decodes blocks in parallel by scanning for the
+// block magic, decoding from each one found. The block magic can
+// also appear inside a block, so a decoded block is only used if
+// it begins where the previous block ended. Blocks are decoded with
+// the block size of the stream being read when they're found, so a
+// block from the next stream is decoded again if the size changes.
+//
+// All bit offsets are from the beginning of the file, buf holding
+// the data beginning at the byte offset offset.
+type parallelReader struct {
+	r           io.Reader
+	concurrency int
+	buf         []byte
+	offset      int64
+	eof         bool
+
+	scanned   int64
+	candidate int64
+	jobs      []*blockJob
+
+	pos         int64
+	blockSize   int
+	crc         uint32
+	readHeader  bool
+	multistream bool
+}
+
+// newParallelReader creates a parallelReader reading from r,
+// decoding up to concurrency blocks at once.
+func newParallelReader(r io.Reader, concurrency int) *parallelReader {
+	return &parallelReader{
+		r:           r,
+		concurrency: concurrency,
+		candidate:   -1,
+		multistream: true,
+	}
+}
+
+// next gets the data for the next block, when the end of the
+// block data is reached the files crc is checked and the next
+// stream is started, or io.EOF is returned if there isn't one.
+// At the start of a stream no data is returned.
+func (pr *parallelReader) next() ([]byte, error) {
+	// Handle reading the file header.
+	if !pr.readHeader {
+		err := pr.readFileHeader(0)
+		if err != nil {
+			return nil, err
+		}
+
+		pr.readHeader = true
+	}
+
+	// Discard blocks started from a block magic found inside the
+	// previous block, and start decoding the blocks after.
+	for len(pr.jobs) > 0 && pr.jobs[0].start < pr.pos {
+		pr.jobs[0] = nil
+		pr.jobs = pr.jobs[1:]
+	}
+	err := pr.queue()
+	if err != nil {
+		return nil, err
+	}
+
+	magic, err := pr.bitsAt(pr.pos, magicLen)
+	if err != nil {
+		return nil, err
+	}
+
+	switch magic {
+	case blockMagic:
+		decoded := pr.decodeNext()
+		if decoded.err != nil {
+			return nil, decoded.err
+		}
+
+		pr.crc = ((pr.crc << 1) | (pr.crc >> 31)) ^ decoded.crc
+		pr.pos = decoded.end
+		if pr.pos > pr.scanned {
+			pr.scanned = pr.pos
+		}
+
+		// Data before the next block is no longer needed.
+		discard := pr.pos/8 - pr.offset
+		pr.buf = pr.buf[discard:]
+		pr.offset += discard
+		return decoded.data, nil
+	case finalMagic:
+		crc, err := pr.bitsAt(pr.pos+magicLen, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		if uint32(crc) != pr.crc {
+			return nil, ErrChecksum
+		}
+		if !pr.multistream {
+			return nil, io.EOF
+		}
+
+		// The next stream begins at the byte after the trailer.
+		start := (pr.pos + magicLen + 32 + 7) / 8 * 8
+		end, err := pr.endsAt(start)
+		if err != nil {
+			return nil, err
+		}
+		if end {
+			return nil, io.EOF
+		}
+
+		return nil, pr.readFileHeader(start)
+	}
+
+	return nil, StructuralError("bad magic value")
+}
+
+// readFileHeader reads the file header of the stream
+// beginning at the bit offset given.
+func (pr *parallelReader) readFileHeader(offset int64) error {
+	header, err := pr.bitsAt(offset, 32)
+	if err != nil {
+		return err
+	}
+
+	level := int(header&0xff) - '0'
+	if header>>8 != fileMagic<<8|'h' || level < BestSpeed ||
+		level > BestCompression {
+		return ErrHeader
+	}
+
+	pr.blockSize = level * baseBlockSize
+	pr.crc = 0
+	pr.pos = offset + 32
+	if pr.pos > pr.scanned {
+		pr.scanned = pr.pos
+	}
+	return nil
+}
+
+// decodeNext gets the decoded block that begins at the current
+// position. If the block was decoded without all of its data
+// because of a block magic inside it, it's decoded again after
+// reading each chunk of data until it has all it needs.
+func (pr *parallelReader) decodeNext() *decodedBlock {
+	var decoded *decodedBlock
+	if len(pr.jobs) > 0 && pr.jobs[0].start == pr.pos {
+		if pr.jobs[0].size == pr.blockSize {
+			decoded = <-pr.jobs[0].decoded
+		}
+		pr.jobs[0] = nil
+		pr.jobs = pr.jobs[1:]
+	}
+
+	if decoded == nil {
+		decoded = pr.decodeAt(pr.pos)
+	}
+	for decoded.err == io.ErrUnexpectedEOF && !pr.eof {
+		err := pr.fill()
+		if err != nil {
+			return &decodedBlock{err: err}
+		}
+
+		decoded = pr.decodeAt(pr.pos)
+	}
+
+	return decoded
+}
+
+// decodeAt decodes the block beginning at the bit
+// offset given, with the data currently read.
+func (pr *parallelReader) decodeAt(offset int64) *decodedBlock {
+	decoded := decodeBlockAt(pr.buf, offset-pr.offset*8, pr.blockSize)
+	decoded.end += pr.offset * 8
+	return decoded
+}
+
+// queue starts decoding blocks in the background from each block
+// magic found, until the max number of blocks are being decoded.
+// A block is only started once the next magic is found, or all
+// the data has been read, so it has all the data it needs.
+func (pr *parallelReader) queue() error {
+	for len(pr.jobs) < pr.concurrency {
+		begin, magic, ok := scanMagic(pr.buf, pr.scanned-pr.offset*8)
+		if !ok {
+			if pr.eof {
+				pr.start()
+				return nil
+			}
+
+			// A magic may begin in the last bits scanned.
+			end := (pr.offset+int64(len(pr.buf)))*8 - magicLen + 1
+			if end > pr.scanned {
+				pr.scanned = end
+			}
+
+			err := pr.fill()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		begin += pr.offset * 8
+		pr.scanned = begin + 1
+
+		pr.start()
+		if magic == blockMagic {
+			pr.candidate = begin
+		}
+	}
+
+	return nil
+}
+
+// start starts decoding the block at the candidate block magic,
+// unless the candidate is inside a block already decoded.
+func (pr *parallelReader) start() {
+	if pr.candidate < pr.pos {
+		pr.candidate = -1
+		return
+	}
+
+	data := pr.buf
+	offset := pr.candidate - pr.offset*8
+	job := &blockJob{
+		start:   pr.candidate,
+		size:    pr.blockSize,
+		decoded: make(chan *decodedBlock, 1),
+	}
+	go func() {
+		decoded := decodeBlockAt(data, offset, job.size)
+		if decoded.err == nil {
+			decoded.end -= offset
+			decoded.end += job.start
+		}
+
+		job.decoded <- decoded
+	}()
+
+	pr.jobs = append(pr.jobs, job)
+	pr.candidate = -1
+}
+
+// fill reads the next chunk of data.
+func (pr *parallelReader) fill() error {
+	chunk := make([]byte, parallelChunkSize)
+	n, err := io.ReadFull(pr.r, chunk)
+	pr.buf = append(pr.buf, chunk[:n]...)
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		pr.eof = true
+		return nil
+	}
+	return err
+}
+
+// endsAt checks if the data ends at the byte
+// aligned bit offset given.
+func (pr *parallelReader) endsAt(offset int64) (bool, error) {
+	for (pr.offset+int64(len(pr.buf)))*8 <= offset {
+		if pr.eof {
+			return true, nil
+		}
+
+		err := pr.fill()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// bitsAt reads n bits beginning at the bit offset given,
+// reading more data if required.
+func (pr *parallelReader) bitsAt(offset int64, n uint) (uint64, error) {
+	for (pr.offset+int64(len(pr.buf)))*8 < offset+int64(n) {
+		if pr.eof {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		err := pr.fill()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	br := bits.NewReaderAt(bytes.NewReader(pr.buf), offset-pr.offset*8)
+	return br.ReadBits(n), nil
+}
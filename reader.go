@@ -0,0 +1,457 @@
+package bzip2
+
+import (
+	"errors"
+	"io"
+
+	"github.com/larzconwell/bzip2/bits"
+	"github.com/larzconwell/bzip2/bwt"
+	"github.com/larzconwell/bzip2/internal/crc32"
+	"github.com/larzconwell/bzip2/internal/huffman"
+	"github.com/larzconwell/bzip2/internal/mtf"
+	"github.com/larzconwell/bzip2/internal/rle"
+	"github.com/larzconwell/bzip2/internal/rle2"
+	"github.com/larzconwell/bzip2/internal/symbols"
+)
+
+var (
+	// ErrHeader is returned when reading data that has an invalid header.
+	ErrHeader = errors.New("bzip2: invalid header")
+	// ErrChecksum is returned when reading data that has an invalid checksum.
+	ErrChecksum = errors.New("bzip2: invalid checksum")
+)
+
+// A StructuralError is returned when the bzip2 data is found
+// to be syntactically invalid.
+type StructuralError string
+
+func (s StructuralError) Error() string {
+	return "bzip2: invalid data: " + string(s)
+}
+
+// ReaderOptions are the options used to create a Reader
+// with NewReaderOptions.
+type ReaderOptions struct {
+	// Concurrency is the number of blocks that can be decoded at
+	// the same time, with the decoded blocks read in order. If
+	// less than 2 blocks are decoded synchronously as they're read.
+	Concurrency int
+}
+
+// Reader is an io.Reader that can be read to retrieve
+// uncompressed data from a bzip2 format compressed file.
+type Reader struct {
+	br          *bits.Reader
+	pr          *parallelReader
+	blockSize   int
+	crc         uint32
+	data        []byte
+	readHeader  bool
+	multistream bool
+	err         error
+}
+
+// NewReader returns a new Reader reading from r. If r does not
+// also implement io.ByteReader, the decompressor may read more
+// data than necessary from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{br: bits.NewReader(r), multistream: true}
+}
+
+// NewReaderOptions is like NewReader but uses the options
+// given to configure the Reader.
+func NewReaderOptions(r io.Reader, opts *ReaderOptions) *Reader {
+	if opts.Concurrency > 1 {
+		return &Reader{
+			pr:          newParallelReader(r, opts.Concurrency),
+			multistream: true,
+		}
+	}
+
+	return NewReader(r)
+}
+
+// Multistream controls whether the reader supports multistream files.
+//
+// If enabled (the default), the Reader expects the input to be a
+// sequence of individually compressed streams, each with its own
+// header and trailer, ending at EOF. The concatenation of a sequence
+// of bzip2 files is read as the concatenation of their data, like
+// bzip2 and pbzip2 do.
+//
+// Calling Multistream(false) disables this behavior, when the Reader
+// reaches the end of the first stream Read returns io.EOF. Without
+// concurrency the underlying reader must implement io.ByteReader in
+// order to be left positioned just after the stream. When blocks are
+// decoded concurrently data after the stream may have been read.
+func (r *Reader) Multistream(ok bool) {
+	r.multistream = ok
+	if r.pr != nil {
+		r.pr.multistream = ok
+	}
+}
+
+// Read reads uncompressed data from the underlying io.Reader,
+// verifying each blocks crc and the files crc as they're read.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.pr != nil {
+		return r.readParallel(p)
+	}
+
+	// Handle reading the file header.
+	if !r.readHeader {
+		r.err = r.readFileHeader()
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		r.readHeader = true
+	}
+
+	for len(r.data) == 0 {
+		r.err = r.read()
+		if r.err != nil {
+			return 0, r.err
+		}
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// readParallel is like Read but gets the blocks
+// decoded by the parallelReader.
+func (r *Reader) readParallel(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		r.data, r.err = r.pr.next()
+		if r.err != nil {
+			return 0, r.err
+		}
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// readFileHeader reads the file header.
+func (r *Reader) readFileHeader() error {
+	var err error
+	r.blockSize, err = readFileHeader(r.br)
+	return err
+}
+
+// read reads the next block, updating the files crc. When
+// the end of the block data is reached the files crc is
+// checked and the next stream is started, or io.EOF is
+// returned if there isn't one.
+func (r *Reader) read() error {
+	magic := r.br.ReadBits(48)
+	if r.br.Err() != nil {
+		return bitsErr(r.br)
+	}
+
+	switch magic {
+	case blockMagic:
+		data, crc, err := readBlock(r.br, r.blockSize)
+		if err != nil {
+			return err
+		}
+
+		r.crc = ((r.crc << 1) | (r.crc >> 31)) ^ crc
+		r.data = data
+		return nil
+	case finalMagic:
+		crc := uint32(r.br.ReadBits(32))
+		if r.br.Err() != nil {
+			return bitsErr(r.br)
+		}
+
+		if crc != r.crc {
+			return ErrChecksum
+		}
+		if !r.multistream {
+			return io.EOF
+		}
+
+		return r.readStreamHeader()
+	}
+
+	return StructuralError("bad magic value")
+}
+
+// readStreamHeader reads the header of the stream following the
+// current one, the data can also end cleanly instead.
+func (r *Reader) readStreamHeader() error {
+	r.br.Align()
+	offset := r.br.Offset()
+
+	blockSize, err := readFileHeader(r.br)
+	if err == io.ErrUnexpectedEOF && r.br.Offset() == offset {
+		return io.EOF
+	}
+	if err != nil {
+		return err
+	}
+
+	r.blockSize = blockSize
+	r.crc = 0
+	return nil
+}
+
+// Reset discards the state of Reader and makes it equivalent
+// to the result of NewReader or NewReaderOptions, but reading
+// from src instead. Multistream is enabled again.
+func (r *Reader) Reset(src io.Reader) {
+	if r.pr != nil {
+		r.pr = newParallelReader(src, r.pr.concurrency)
+	} else {
+		r.br = bits.NewReader(src)
+	}
+	r.blockSize = 0
+	r.crc = 0
+	r.data = nil
+	r.readHeader = false
+	r.multistream = true
+	r.err = nil
+}
+
+// readFileHeader reads the file header from the bit
+// reader given, returning the block size used.
+func readFileHeader(br *bits.Reader) (int, error) {
+	magic := br.ReadBits(16)
+	h := br.ReadBits(8)
+	level := int(br.ReadBits(8)) - '0'
+	if br.Err() != nil {
+		return 0, bitsErr(br)
+	}
+
+	if magic != fileMagic || h != 'h' || level < BestSpeed ||
+		level > BestCompression {
+		return 0, ErrHeader
+	}
+
+	return level * baseBlockSize, nil
+}
+
+// readBlock reads a block following the block magic from the bit
+// reader given, returning the decompressed data and the blocks crc.
+// The data decoded from the block can't exceed size bytes.
+func readBlock(br *bits.Reader, size int) ([]byte, uint32, error) {
+	block, err := readMTFBlock(br, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	mtfData := block.data
+
+	// MTF step.
+	bwtData := mtfData
+	mtf.InverseTransform(block.syms, bwtData, mtfData)
+
+	// BWT step.
+	rleData := make([]byte, len(bwtData))
+	bwt.InverseTransform(rleData, bwtData, block.bwtidx)
+
+	// RLE step.
+	data := rle.Decode(rleData)
+	if crc32.Update(0, data) != block.crc {
+		return nil, 0, ErrChecksum
+	}
+
+	return data, block.crc, nil
+}
+
+// mtfBlock is a block decoded up to its MTF encoded data,
+// which has the same length as the blocks RLE encoded data.
+type mtfBlock struct {
+	crc    uint32
+	bwtidx int
+	syms   symbols.ReducedSet
+	data   []byte
+}
+
+// readMTFBlock reads a block following the block magic from the
+// bit reader given, decoding it up to its MTF encoded data. The
+// data decoded from the block can't exceed size bytes.
+func readMTFBlock(br *bits.Reader, size int) (*mtfBlock, error) {
+	// Read the block header.
+	crc := uint32(br.ReadBits(32))
+	randomized := br.ReadBit()
+	bwtidx := int(br.ReadBits(24))
+
+	// Read the contents that build the decoding steps.
+	reducedSyms := readSymbolBitmaps(br)
+	numTrees := int(br.ReadBits(3))
+	numSelections := int(br.ReadBits(15))
+	if br.Err() != nil {
+		return nil, bitsErr(br)
+	}
+	if randomized {
+		return nil, StructuralError("randomized blocks are not supported")
+	}
+	if len(reducedSyms) == 0 {
+		return nil, StructuralError("no symbols used")
+	}
+	if numTrees < 2 || numTrees > 6 {
+		return nil, StructuralError("invalid number of huffman trees")
+	}
+	if numSelections == 0 {
+		return nil, StructuralError("no huffman tree selections")
+	}
+
+	selections, err := readTreeSelections(br, numTrees, numSelections)
+	if err != nil {
+		return nil, err
+	}
+
+	decoders, err := readTreeCodes(br, numTrees, len(reducedSyms)+2)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the encoded contents, using the huffman trees given
+	// switching them out every 50 symbols.
+	eob := uint16(len(reducedSyms) + 1)
+	var rle2Data []uint16
+	for idx, decoded := 0, 0; ; decoded++ {
+		if decoded == huffman.TreeSelectionLimit {
+			decoded = 0
+			idx++
+			if idx == len(selections) {
+				return nil, StructuralError("not enough huffman tree selections")
+			}
+		}
+
+		v, err := decoders[selections[idx]].Decode(br)
+		if err != nil {
+			if err == huffman.ErrInvalidCode {
+				return nil, StructuralError("invalid huffman code")
+			}
+
+			return nil, bitsErr(br)
+		}
+
+		rle2Data = append(rle2Data, v)
+		if v == eob {
+			break
+		}
+	}
+
+	// RLE2 step.
+	mtfData, err := rle2.Decode(reducedSyms, rle2Data, size)
+	if err != nil {
+		return nil, StructuralError("data exceeds block size")
+	}
+	if bwtidx >= len(mtfData) {
+		return nil, StructuralError("bwt index out of bounds")
+	}
+
+	return &mtfBlock{crc: crc, bwtidx: bwtidx, syms: reducedSyms, data: mtfData}, nil
+}
+
+// readSymbolBitmaps reads the bitmaps for the used symbols.
+func readSymbolBitmaps(br *bits.Reader) symbols.ReducedSet {
+	reduced := make(symbols.ReducedSet, 0, 256)
+
+	rangesUsed := br.ReadBits(16)
+	for i := 0; i < 16; i++ {
+		if rangesUsed&(1<<uint(15-i)) == 0 {
+			continue
+		}
+
+		r := br.ReadBits(16)
+		for j := 0; j < 16; j++ {
+			if r&(1<<uint(15-j)) != 0 {
+				reduced = append(reduced, byte(16*i+j))
+			}
+		}
+	}
+
+	return reduced
+}
+
+// readTreeSelections reads the unary encoded huffman tree selections
+// reversing the MTF transform applied to them.
+func readTreeSelections(br *bits.Reader, numTrees, numSelections int) ([]byte, error) {
+	selections := make([]byte, numSelections)
+	for i := range selections {
+		selection := 0
+		for br.ReadBit() {
+			selection++
+			if selection == numTrees {
+				return nil, StructuralError("invalid huffman tree selection")
+			}
+		}
+		if br.Err() != nil {
+			return nil, bitsErr(br)
+		}
+
+		selections[i] = byte(selection)
+	}
+
+	treeSelectionSymbols := make(symbols.ReducedSet, numTrees)
+	for i := range treeSelectionSymbols {
+		treeSelectionSymbols[i] = byte(i)
+	}
+	mtf.InverseTransform(treeSelectionSymbols, selections, selections)
+
+	return selections, nil
+}
+
+// readTreeCodes reads the delta encoded code-lengths for the
+// huffman trees, returning the decoders for each tree.
+func readTreeCodes(br *bits.Reader, numTrees, numSyms int) ([]*huffman.Decoder, error) {
+	decoders := make([]*huffman.Decoder, numTrees)
+	lens := make([]int, numSyms)
+
+	for i := range decoders {
+		codelen := int(br.ReadBits(5))
+
+		// Read the code-lengths as modifications to the current length.
+		for j := range lens {
+			for {
+				if codelen < 1 || codelen > huffman.MaxCodeLen {
+					return nil, StructuralError("invalid huffman code-length")
+				}
+				if !br.ReadBit() {
+					break
+				}
+
+				// 2 is increment, 3 is decrement.
+				if br.ReadBit() {
+					codelen--
+				} else {
+					codelen++
+				}
+			}
+
+			lens[j] = codelen
+		}
+		if br.Err() != nil {
+			return nil, bitsErr(br)
+		}
+
+		var err error
+		decoders[i], err = huffman.NewDecoder(lens)
+		if err != nil {
+			return nil, StructuralError("invalid huffman code-lengths")
+		}
+	}
+
+	return decoders, nil
+}
+
+// bitsErr gets the error for the bit reader, the end of the
+// data is unexpected when reading so io.EOF isn't returned.
+func bitsErr(br *bits.Reader) error {
+	err := br.Err()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return err
+}
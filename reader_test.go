@@ -0,0 +1,375 @@
+package bzip2
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/larzconwell/bzip2/internal/testhelpers"
+)
+
+// linesData produces the uncompressed contents of testdata/lines.txt.bz2.
+func linesData() []byte {
+	var buf bytes.Buffer
+
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&buf, "%d: the quick brown fox jumps over the lazy dog\n", i)
+		if i%1000 == 0 {
+			buf.Write(bytes.Repeat([]byte("x"), 1000))
+			buf.WriteByte('\n')
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestReaderEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	err := writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadAll(NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) != 0 {
+		t.Error("Output should be empty. Got", len(out), "bytes")
+	}
+}
+
+func TestReaderIncompleteBlock(t *testing.T) {
+	var buf bytes.Buffer
+	expected := []byte("banana")
+
+	writer := NewWriter(&buf)
+	_, err := writer.Write(expected)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadAll(NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != string(expected) {
+		t.Error("Output is incorrect. Got", string(out), "wanted",
+			string(expected))
+	}
+}
+
+func TestReaderMultiBlock(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := linesData()
+
+	out, err := ioutil.ReadAll(NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, expected) {
+		t.Error("Output is incorrect.")
+	}
+}
+
+func TestReaderWriterMultiBlock(t *testing.T) {
+	var buf bytes.Buffer
+	expected := testhelpers.RandomRunData(2*baseBlockSize + baseBlockSize/2)
+
+	writer, _ := NewWriterLevel(&buf, 1)
+	_, err := writer.Write(expected)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadAll(NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, expected) {
+		t.Error("Output is incorrect.")
+	}
+}
+
+func TestReaderReset(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewReader(bytes.NewReader([]byte("invalid")))
+	_, err = ioutil.ReadAll(reader)
+	if err == nil {
+		t.Fatal("Reading invalid data should return an error")
+	}
+
+	reader.Reset(bytes.NewReader(compressed))
+	out, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, linesData()) {
+		t.Error("Output is incorrect.")
+	}
+}
+
+func TestReaderInvalidHeader(t *testing.T) {
+	_, err := ioutil.ReadAll(NewReader(bytes.NewReader([]byte("BZh0"))))
+	if err != ErrHeader {
+		t.Error("Invalid header should return ErrHeader. Got", err)
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewReader(bytes.NewReader(compressed[:len(compressed)/2]))
+	_, err = ioutil.ReadAll(reader)
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Truncated data should return io.ErrUnexpectedEOF. Got", err)
+	}
+}
+
+func TestReaderBlockChecksum(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first blocks crc directly follows the header and block magic.
+	compressed[10] ^= 0xff
+
+	_, err = ioutil.ReadAll(NewReader(bytes.NewReader(compressed)))
+	if err != ErrChecksum {
+		t.Error("Invalid block crc should return ErrChecksum. Got", err)
+	}
+}
+
+func TestReaderFileChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	_, err := writer.Write([]byte("banana"))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The files crc is the last 32 bits before the padding.
+	compressed := buf.Bytes()
+	compressed[len(compressed)-2] ^= 0xff
+
+	_, err = ioutil.ReadAll(NewReader(bytes.NewReader(compressed)))
+	if err != ErrChecksum {
+		t.Error("Invalid file crc should return ErrChecksum. Got", err)
+	}
+}
+
+func BenchmarkReader(b *testing.B) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := io.Copy(ioutil.Discard, NewReader(bytes.NewReader(compressed)))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReaderConcurrent(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewReaderOptions(bytes.NewReader(compressed), &ReaderOptions{Concurrency: 4})
+	out, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, linesData()) {
+		t.Error("Output is incorrect.")
+	}
+}
+
+// countingReader is an io.Reader counting the bytes read.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func TestReaderConcurrentPartialBlock(t *testing.T) {
+	data := make([]byte, 2*parallelChunkSize)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	var compressed bytes.Buffer
+	writer, err := NewWriterLevel(&compressed, BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write(data)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only part of the first block has been read, like when a
+	// block magic inside it was taken as the next block.
+	rest := &countingReader{r: bytes.NewReader(compressed.Bytes()[1000:])}
+	pr := newParallelReader(rest, 4)
+	pr.buf = compressed.Bytes()[:1000]
+	err = pr.readFileHeader(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := pr.decodeNext()
+	if decoded.err != nil {
+		t.Fatal(decoded.err)
+	}
+	if !bytes.Equal(decoded.data, data[:len(decoded.data)]) {
+		t.Error("Decoded block is incorrect")
+	}
+	if rest.n > parallelChunkSize {
+		t.Error("Only the data the block needs should be read. Got", rest.n, "bytes")
+	}
+}
+
+func TestReaderConcurrentTruncated(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewReaderOptions(bytes.NewReader(compressed[:len(compressed)-4]),
+		&ReaderOptions{Concurrency: 4})
+	_, err = ioutil.ReadAll(reader)
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Truncated data should return io.ErrUnexpectedEOF. Got", err)
+	}
+}
+
+func TestReaderConcurrentBlockChecksum(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed[10] ^= 0xff
+
+	reader := NewReaderOptions(bytes.NewReader(compressed), &ReaderOptions{Concurrency: 4})
+	_, err = ioutil.ReadAll(reader)
+	if err != ErrChecksum {
+		t.Error("Invalid block crc should return ErrChecksum. Got", err)
+	}
+}
+
+// multistreamData concatenates testdata/lines.txt.bz2 with a stream
+// using a different level, returning the expected uncompressed data.
+func multistreamData(t *testing.T) ([]byte, []byte) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(compressed)
+	writer, err := NewWriterLevel(buf, BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write([]byte("banana"))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes(), append(linesData(), "banana"...)
+}
+
+func TestReaderMultistream(t *testing.T) {
+	compressed, expected := multistreamData(t)
+
+	for _, concurrency := range []int{1, 4} {
+		reader := NewReaderOptions(bytes.NewReader(compressed),
+			&ReaderOptions{Concurrency: concurrency})
+		out, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(out, expected) {
+			t.Error("Output is incorrect with concurrency", concurrency)
+		}
+	}
+}
+
+func TestReaderMultistreamDisabled(t *testing.T) {
+	compressed, _ := multistreamData(t)
+	rd := bytes.NewReader(compressed)
+
+	reader := NewReader(rd)
+	reader.Multistream(false)
+	out, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, linesData()) {
+		t.Error("Output of the first stream is incorrect.")
+	}
+
+	// The underlying reader is left at the second stream.
+	reader.Reset(rd)
+	out, err = ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "banana" {
+		t.Error("Output of the second stream is incorrect. Got", string(out))
+	}
+}
+
+func TestReaderMultistreamTrailingGarbage(t *testing.T) {
+	compressed, _ := multistreamData(t)
+	compressed = append(compressed, "BZ"...)
+
+	_, err := ioutil.ReadAll(NewReader(bytes.NewReader(compressed)))
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Partial stream header should return io.ErrUnexpectedEOF. Got", err)
+	}
+}
@@ -0,0 +1,147 @@
+package bzip2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/larzconwell/bzip2/bits"
+)
+
+// RecoveredBlock is a block found by Recover.
+type RecoveredBlock struct {
+	// Offset is the bit offset of the block from the beginning
+	// of the data, pointing at the block magic.
+	Offset int64
+	// Len is the number of bits in the block, including
+	// the block magic.
+	Len int64
+	// Data is the block as a standalone single block bzip2 stream.
+	Data []byte
+	// Err is the error found verifying Data, if nil
+	// the block was recovered.
+	Err error
+}
+
+// Recover scans possibly damaged bzip2 data from r bit-by-bit for
+// blocks, where each block runs from a block magic to the next block
+// or final magic. Each block found is given to fn as a standalone
+// single block stream, along with the results of verifying it.
+//
+// Any error from fn stops the scan and is returned.
+func Recover(r io.Reader, fn func(*RecoveredBlock) error) error {
+	br := bits.NewReader(r)
+	level := uint64(BestCompression)
+
+	// Use the level from the file header if it's intact,
+	// otherwise the largest level can fit any block.
+	header := br.ReadBits(32)
+	headerLevel := header&0xff - '0'
+	if br.Err() == nil && header>>8 == fileMagic<<8|'h' &&
+		headerLevel >= BestSpeed && headerLevel <= BestCompression {
+		level = headerLevel
+	}
+	if br.Err() != nil {
+		return nil
+	}
+
+	var block *bytes.Buffer
+	var bw *bits.Writer
+	var start int64
+	var window uint64
+	windowLen := 0
+
+	// finishBlock finishes the current block which ends at end.
+	finishBlock := func(end int64) error {
+		if block == nil {
+			return nil
+		}
+
+		// Writing to memory can't fail.
+		bw.Flush()
+		recovered := recoverBlock(level, &compressedBlock{
+			data: block.Bytes(),
+			bits: bw.BufferedBits(),
+			n:    bw.Buffered(),
+		})
+		recovered.Offset = start
+		recovered.Len = end - start
+
+		block = nil
+		return fn(recovered)
+	}
+
+	offset := int64(32)
+	for {
+		bit := br.ReadBits(1)
+		if br.Err() != nil {
+			break
+		}
+		window = (window << 1) | bit
+		windowLen++
+		offset++
+
+		// The bit leaving the window belongs to the current block.
+		if block != nil && windowLen > magicLen {
+			bw.WriteBits(1, (window>>magicLen)&1)
+		}
+		if windowLen < magicLen {
+			continue
+		}
+
+		magic := window & magicMask
+		if magic != blockMagic && magic != finalMagic {
+			continue
+		}
+
+		err := finishBlock(offset - magicLen)
+		if err != nil {
+			return err
+		}
+		if magic == blockMagic {
+			block = &bytes.Buffer{}
+			bw = bits.NewWriter(block)
+			start = offset - magicLen
+			bw.WriteBits(magicLen, blockMagic)
+		}
+
+		window = 0
+		windowLen = 0
+	}
+
+	// The bits left in the window are the end of the last block.
+	if block != nil {
+		n := uint(windowLen)
+		if n > magicLen {
+			n = magicLen
+		}
+
+		bw.WriteBits(n, window&(1<<n-1))
+	}
+	return finishBlock(offset)
+}
+
+// recoverBlock creates a standalone stream for the compressed
+// block given, verifying it can be decoded.
+func recoverBlock(level uint64, block *compressedBlock) *RecoveredBlock {
+	var buf bytes.Buffer
+	bw := bits.NewWriter(&buf)
+
+	// The blocks crc is directly after the block magic, with only
+	// one block it's the same as the streams crc.
+	var crc uint32
+	if len(block.data) >= 10 {
+		crc = binary.BigEndian.Uint32(block.data[6:10])
+	}
+
+	bw.WriteBits(16, fileMagic)
+	bw.WriteBits(8, 'h')
+	bw.WriteBits(8, '0'+level)
+	block.WriteBlock(bw)
+	bw.WriteBits(magicLen, finalMagic)
+	bw.WriteBits(32, uint64(crc))
+	bw.Close()
+
+	_, err := Verify(bytes.NewReader(buf.Bytes()))
+	return &RecoveredBlock{Data: buf.Bytes(), Err: err}
+}
@@ -0,0 +1,94 @@
+package bzip2
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the second blocks crc.
+	offset := report.Blocks[1].Offset + magicLen
+	compressed[offset/8+1] ^= 0xff
+
+	var recovered []*RecoveredBlock
+	err = Recover(bytes.NewReader(compressed), func(block *RecoveredBlock) error {
+		recovered = append(recovered, block)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recovered) != 3 {
+		t.Fatal("Wrong number of blocks recovered. Got", len(recovered))
+	}
+	if recovered[1].Err == nil {
+		t.Error("The corrupt block shouldn't verify")
+	}
+
+	var out []byte
+	for i, block := range recovered {
+		if block.Offset != report.Blocks[i].Offset {
+			t.Error("Block offset incorrect. Got", block.Offset, "wanted",
+				report.Blocks[i].Offset)
+		}
+		if i == 1 {
+			continue
+		}
+		if block.Err != nil {
+			t.Fatal(block.Err)
+		}
+
+		data, err := ioutil.ReadAll(NewReader(bytes.NewReader(block.Data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, data...)
+	}
+
+	expected := linesData()
+	first := report.Blocks[0].Size
+	last := report.Blocks[2].Size
+	if !bytes.Equal(out[:first], expected[:first]) ||
+		!bytes.Equal(out[first:], expected[len(expected)-last:]) {
+		t.Error("Recovered data is incorrect")
+	}
+}
+
+func TestRecoverTruncated(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed = compressed[:len(compressed)-100]
+
+	var recovered []*RecoveredBlock
+	err = Recover(bytes.NewReader(compressed), func(block *RecoveredBlock) error {
+		recovered = append(recovered, block)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recovered) != 3 {
+		t.Fatal("Wrong number of blocks recovered. Got", len(recovered))
+	}
+	if recovered[0].Err != nil || recovered[1].Err != nil {
+		t.Error("The intact blocks should verify")
+	}
+	if recovered[2].Err == nil {
+		t.Error("The truncated block shouldn't verify")
+	}
+}
@@ -0,0 +1,61 @@
+package bzip2
+
+import (
+	"math/bits"
+)
+
+// gearTable maps each byte to a random value for the rolling hash
+// used by rsyncable Writers. It's generated from a fixed seed so
+// the block boundaries found never change between versions.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+
+	seed := uint64(0x627a69703272)
+	for i := range table {
+		// splitmix64.
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+
+	return table
+}()
+
+// chunker finds content defined block boundaries for rsyncable
+// Writers using a gear hash, which only depends on the last 64
+// bytes hashed. A block can end once it has minSize bytes, at the
+// first byte where the top bits of the hash are zero.
+type chunker struct {
+	minSize int
+	shift   uint
+}
+
+// newChunker creates a chunker for blocks of the given size. Blocks
+// have at least a quarter of the size, and on average end about the
+// same number of bytes later if the block size isn't reached first.
+func newChunker(size int) *chunker {
+	avg := size / 4
+	return &chunker{
+		minSize: avg,
+		shift:   uint(64 - (bits.Len(uint(avg)) - 1)),
+	}
+}
+
+// boundary hashes p after the bytes in the block, returning the
+// number of bytes in p up to and including the first boundary.
+// If there's no boundary -1 is returned.
+//
+// The hash is kept in the block, so it restarts with each block and
+// the boundaries don't depend on how the data is split into writes.
+func (c *chunker) boundary(b *block, p []byte) int {
+	for i, v := range p {
+		b.hash = (b.hash << 1) + gearTable[v]
+		if b.dataLen+i+1 >= c.minSize && b.hash>>c.shift == 0 {
+			return i + 1
+		}
+	}
+
+	return -1
+}
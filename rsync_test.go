@@ -0,0 +1,106 @@
+package bzip2
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+// rsyncData returns text that doesn't repeat, so the
+// block boundaries depend on the content.
+func rsyncData() []byte {
+	var buf bytes.Buffer
+	words := []string{"the", "quick", "brown", "fox", "jumps", "over", "lazy", "dog"}
+
+	rnd := rand.New(rand.NewSource(1))
+	for buf.Len() < 1000000 {
+		fmt.Fprintf(&buf, "%s %d\n", words[rnd.Intn(len(words))], rnd.Intn(100000))
+	}
+
+	return buf.Bytes()
+}
+
+// rsyncStreams compresses data with an rsyncable Writer, returning
+// the output and the bytes of each stream.
+func rsyncStreams(t *testing.T, data []byte, chunk int) ([]byte, [][]byte) {
+	var buf bytes.Buffer
+	writer, err := NewWriterOptions(&buf, &WriterOptions{Level: BestSpeed, Rsyncable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p := data; len(p) > 0 && err == nil; {
+		n := chunk
+		if n > len(p) {
+			n = len(p)
+		}
+
+		_, err = writer.Write(p[:n])
+		p = p[n:]
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.Bytes()
+	offsets := append(writer.StreamOffsets(), int64(len(out)))
+	streams := make([][]byte, len(offsets)-1)
+	for i := range streams {
+		streams[i] = out[offsets[i]:offsets[i+1]]
+	}
+
+	return out, streams
+}
+
+func TestWriterRsyncable(t *testing.T) {
+	data := rsyncData()
+	modified := append(append(append([]byte(nil), data[:100]...), "inserted"...), data[100:]...)
+
+	_, streams := rsyncStreams(t, data, len(data))
+	out, modifiedStreams := rsyncStreams(t, modified, len(modified))
+	if len(streams) < 10 {
+		t.Fatal("Blocks should end at content defined boundaries, each in its own stream. Got",
+			len(streams), "streams")
+	}
+
+	existing := make(map[string]bool)
+	for _, stream := range streams {
+		existing[string(stream)] = true
+	}
+
+	// Only the blocks around the inserted data should change.
+	changed := 0
+	for _, stream := range modifiedStreams {
+		if !existing[string(stream)] {
+			changed++
+		}
+	}
+	if changed > 2 {
+		t.Error("Blocks after the inserted data should be identical. Got", changed,
+			"changed blocks of", len(modifiedStreams))
+	}
+
+	decompressed, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, modified) {
+		t.Error("Output of the rsyncable data is incorrect")
+	}
+}
+
+func TestWriterRsyncableWrites(t *testing.T) {
+	data := rsyncData()
+
+	expected, _ := rsyncStreams(t, data, len(data))
+	out, _ := rsyncStreams(t, data, 999)
+	if !bytes.Equal(out, expected) {
+		t.Error("Block boundaries shouldn't depend on the size of writes")
+	}
+}
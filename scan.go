@@ -0,0 +1,39 @@
+package bzip2
+
+const (
+	// magicLen is the number of bits in the block and final magic.
+	magicLen = 48
+	// magicMask masks the bits used by the block and final magic.
+	magicMask = 1<<magicLen - 1
+)
+
+// scanMagic finds the first block or final magic in data beginning
+// at or after the bit offset from, returning the bit offset it
+// begins at and the magic found.
+func scanMagic(data []byte, from int64) (int64, uint64, bool) {
+	if from < 0 {
+		from = 0
+	}
+	start := int(from / 8)
+
+	var window uint64
+	for i := start; i < len(data); i++ {
+		window = (window << 8) | uint64(data[i])
+		end := int64(i+1) * 8
+
+		// Check each bit offset ending in the byte, earliest first.
+		for shift := uint(7); shift < 8; shift-- {
+			begin := end - int64(shift) - magicLen
+			if begin < from || begin < int64(start)*8 {
+				continue
+			}
+
+			magic := (window >> shift) & magicMask
+			if magic == blockMagic || magic == finalMagic {
+				return begin, magic, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
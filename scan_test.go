@@ -0,0 +1,34 @@
+package bzip2
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/larzconwell/bzip2/bits"
+)
+
+func TestScanMagicUnaligned(t *testing.T) {
+	var buf bytes.Buffer
+	bw := bits.NewWriter(&buf)
+	bw.WriteBits(3, 5)
+	bw.WriteBits(magicLen, blockMagic)
+	bw.WriteBits(11, 1458)
+	bw.WriteBits(magicLen, finalMagic)
+	bw.WriteBits(2, 0)
+	bw.Flush()
+
+	offset, magic, ok := scanMagic(buf.Bytes(), 0)
+	if !ok || offset != 3 || magic != blockMagic {
+		t.Error("Block magic not found at the expected offset. Got", offset, ok)
+	}
+
+	offset, magic, ok = scanMagic(buf.Bytes(), offset+1)
+	if !ok || offset != 62 || magic != finalMagic {
+		t.Error("Final magic not found at the expected offset. Got", offset, ok)
+	}
+
+	_, _, ok = scanMagic(buf.Bytes(), offset+1)
+	if ok {
+		t.Error("No magic should be found after the final magic")
+	}
+}
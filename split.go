@@ -0,0 +1,175 @@
+package bzip2
+
+import (
+	"io"
+
+	"github.com/larzconwell/bzip2/bits"
+)
+
+// SplitReader reads the blocks of bzip2 data that begin in a byte
+// range, like Hadoop's BZip2Codec. The first block read is the first
+// with its block magic beginning at or after the start of the range,
+// and blocks are read until one begins at or after the end of the
+// range. Splitting data into consecutive ranges and reading each with
+// a SplitReader reads every block exactly once.
+//
+// Each block is checked against its crc, but the block size and crc
+// of the streams they're in are not since a range only contains part
+// of a stream. The block magic can also appear inside a block, so a
+// block beginning inside the range is only used if it decodes. A
+// corrupt block that begins the range can't be told apart from that
+// and is skipped.
+type SplitReader struct {
+	r     io.ReaderAt
+	start int64
+	end   int64
+
+	br     *bits.Reader
+	offset int64
+	data   []byte
+	err    error
+}
+
+// NewSplitReader returns a new SplitReader reading the blocks that
+// begin in the byte range [start, end) of the bzip2 data in r.
+func NewSplitReader(r io.ReaderAt, start, end int64) *SplitReader {
+	return &SplitReader{r: r, start: start, end: end, offset: -1}
+}
+
+// Read reads uncompressed data from the blocks in the range.
+func (sr *SplitReader) Read(p []byte) (int, error) {
+	if sr.err != nil {
+		return 0, sr.err
+	}
+
+	for len(sr.data) == 0 {
+		if sr.br == nil {
+			sr.err = sr.readFirst()
+		} else {
+			sr.err = sr.read()
+		}
+		if sr.err != nil {
+			return 0, sr.err
+		}
+	}
+
+	n := copy(p, sr.data)
+	sr.data = sr.data[n:]
+	return n, nil
+}
+
+// BlockOffset gets the bit offset of the block being read,
+// pointing at its block magic. If no block has been read -1
+// is returned.
+func (sr *SplitReader) BlockOffset() int64 {
+	return sr.offset
+}
+
+// readFirst finds and reads the first block in the range.
+func (sr *SplitReader) readFirst() error {
+	from := sr.start * 8
+	for {
+		offset, err := sr.findBlock(from)
+		if err != nil {
+			return err
+		}
+		if offset < 0 || offset >= sr.end*8 {
+			return io.EOF
+		}
+
+		br := bits.NewReaderAt(sr.r, offset+magicLen)
+		data, _, err := readBlock(br, BestCompression*baseBlockSize)
+
+		// Block magics found can be false positives, which may
+		// seem to be cut off by the end of the data.
+		if _, ok := err.(StructuralError); ok || err == ErrChecksum ||
+			err == io.ErrUnexpectedEOF {
+			from = offset + 1
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		sr.br = br
+		sr.offset = offset
+		sr.data = data
+		return nil
+	}
+}
+
+// read reads the block following the previous one, continuing
+// into the next stream if the previous stream ended. When the
+// block begins at or after the end of the range or the data
+// ends io.EOF is returned.
+func (sr *SplitReader) read() error {
+	for {
+		offset := sr.br.Offset()
+		if offset >= sr.end*8 {
+			return io.EOF
+		}
+
+		magic := sr.br.ReadBits(magicLen)
+		if sr.br.Err() != nil {
+			return bitsErr(sr.br)
+		}
+
+		switch magic {
+		case blockMagic:
+			data, _, err := readBlock(sr.br, BestCompression*baseBlockSize)
+			if err != nil {
+				return err
+			}
+
+			sr.offset = offset
+			sr.data = data
+			return nil
+		case finalMagic:
+			sr.br.ReadBits(32)
+			sr.br.Align()
+			offset = sr.br.Offset()
+
+			_, err := readFileHeader(sr.br)
+			if err == io.ErrUnexpectedEOF && sr.br.Offset() == offset {
+				return io.EOF
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			return StructuralError("bad magic value")
+		}
+	}
+}
+
+// findBlock finds the first block magic beginning at or after
+// the bit offset from, returning -1 if there isn't one.
+func (sr *SplitReader) findBlock(from int64) (int64, error) {
+	buf := make([]byte, parallelChunkSize)
+	for {
+		offset := from / 8
+		n, err := sr.r.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return -1, err
+		}
+
+		for begin := from - offset*8; ; begin++ {
+			var magic uint64
+			var ok bool
+			begin, magic, ok = scanMagic(buf[:n], begin)
+			if !ok {
+				break
+			}
+
+			if magic == blockMagic {
+				return offset*8 + begin, nil
+			}
+		}
+		if n < len(buf) {
+			return -1, nil
+		}
+
+		// A magic may begin in the last bits scanned.
+		from = (offset+int64(n))*8 - magicLen + 1
+	}
+}
@@ -0,0 +1,113 @@
+package bzip2
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// readSplits reads the data in n consecutive ranges of compressed,
+// returning the concatenated data from each.
+func readSplits(t *testing.T, compressed []byte, n int) []byte {
+	var out []byte
+	size := int64(len(compressed))
+
+	for i := int64(0); i < int64(n); i++ {
+		start := size * i / int64(n)
+		end := size * (i + 1) / int64(n)
+
+		data, err := ioutil.ReadAll(NewSplitReader(bytes.NewReader(compressed), start, end))
+		if err != nil {
+			t.Fatal("Reading range", start, end, "failed:", err)
+		}
+		out = append(out, data...)
+	}
+
+	return out
+}
+
+func TestSplitReader(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := linesData()
+
+	for n := 1; n <= 8; n++ {
+		out := readSplits(t, compressed, n)
+		if !bytes.Equal(out, expected) {
+			t.Error("Output is incorrect with", n, "splits")
+		}
+	}
+}
+
+func TestSplitReaderFalseMagicAtEnd(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A block magic in the last range that's cut off by the end of the data.
+	start := int64(len(compressed))
+	data := append(append([]byte(nil), compressed...), "\x31\x41\x59\x26\x53\x59\xff\xff"...)
+
+	out, err := ioutil.ReadAll(NewSplitReader(bytes.NewReader(data), start, int64(len(data))))
+	if err != nil {
+		t.Fatal("False block magic should be skipped. Got", err)
+	}
+	if len(out) != 0 {
+		t.Error("Range without blocks should be empty. Got", len(out), "bytes")
+	}
+}
+
+func TestSplitReaderMultistream(t *testing.T) {
+	compressed, expected := multistreamData(t)
+
+	for n := 1; n <= 8; n++ {
+		out := readSplits(t, compressed, n)
+		if !bytes.Equal(out, expected) {
+			t.Error("Output is incorrect with", n, "splits")
+		}
+	}
+}
+
+func TestSplitReaderBlockOffset(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := BuildIndex(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Begin the range a byte into the first block, so the second block is first.
+	reader := NewSplitReader(bytes.NewReader(compressed), 5, int64(len(compressed)))
+	if reader.BlockOffset() != -1 {
+		t.Error("Block offset should be -1 before reading. Got", reader.BlockOffset())
+	}
+
+	_, err = reader.Read(make([]byte, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.BlockOffset() != index.Blocks[1].Offset {
+		t.Error("Block offset incorrect. Got", reader.BlockOffset(), "wanted",
+			index.Blocks[1].Offset)
+	}
+}
+
+func TestSplitReaderEmptyRange(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := int64(len(compressed))
+	_, err = NewSplitReader(bytes.NewReader(compressed), size-10, size).Read(make([]byte, 1))
+	if err != io.EOF {
+		t.Error("Range without blocks should return io.EOF. Got", err)
+	}
+}
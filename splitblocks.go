@@ -0,0 +1,100 @@
+package bzip2
+
+import (
+	"errors"
+	"io"
+
+	"github.com/larzconwell/bzip2/bits"
+)
+
+var (
+	// errSplitOptions occurs when splitting without
+	// a block count or size to split at.
+	errSplitOptions = errors.New("bzip2: split requires a block count or size")
+)
+
+// SplitOptions are the options used to decide where Split
+// divides the blocks.
+type SplitOptions struct {
+	// Blocks is the number of blocks in each part.
+	Blocks int
+	// Size is the target number of compressed bytes in each
+	// part, a part ends after the block that reaches it.
+	Size int64
+}
+
+// Split divides the bzip2 data read from r, including any
+// concatenated streams, at block boundaries without compressing
+// the data again. Each part is a standalone stream containing a
+// contiguous run of blocks, with the combined crc computed from
+// the block crcs. A part ends once it reaches either limit in
+// opts, or when the block size of the streams change.
+//
+// The parts are written to the io.WriteCloser returned by create
+// for each, numbered from 0, which is closed once the part is
+// complete. The block and stream crcs are verified as the data is
+// split, a stream crc only after the parts with its blocks have
+// been written.
+func Split(r io.Reader, opts *SplitOptions, create func(part int) (io.WriteCloser, error)) error {
+	if opts.Blocks <= 0 && opts.Size <= 0 {
+		return errSplitOptions
+	}
+
+	var part *joiner
+	var out io.WriteCloser
+	parts := 0
+
+	endPart := func() error {
+		err := part.close()
+		closeErr := out.Close()
+		if err == nil {
+			err = closeErr
+		}
+
+		part = nil
+		return err
+	}
+
+	err := readBlocks(r, func(block *copiedBlock) error {
+		if part != nil && part.level != block.level {
+			err := endPart()
+			if err != nil {
+				return err
+			}
+		}
+
+		if part == nil {
+			var err error
+			out, err = create(parts)
+			if err != nil {
+				return err
+			}
+
+			part = &joiner{bw: bits.NewWriter(out)}
+			parts++
+		}
+
+		err := part.add(block)
+		if err != nil {
+			return err
+		}
+
+		if (opts.Blocks > 0 && part.blocks >= opts.Blocks) ||
+			(opts.Size > 0 && part.bw.Offset()/8 >= opts.Size) {
+			return endPart()
+		}
+		return nil
+	})
+	if err != nil {
+		if part != nil {
+			out.Close()
+		}
+
+		return err
+	}
+
+	if part != nil {
+		return endPart()
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package bzip2
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// nopCloser is a bytes.Buffer that can be closed.
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// splitParts splits compressed with the options given,
+// returning the parts.
+func splitParts(t *testing.T, compressed []byte, opts *SplitOptions) []*bytes.Buffer {
+	var parts []*bytes.Buffer
+	err := Split(bytes.NewReader(compressed), opts, func(part int) (io.WriteCloser, error) {
+		if part != len(parts) {
+			t.Error("Parts should be numbered in order. Got", part)
+		}
+
+		parts = append(parts, &bytes.Buffer{})
+		return nopCloser{parts[len(parts)-1]}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parts
+}
+
+func TestSplit(t *testing.T) {
+	compressed, expected := multistreamData(t)
+
+	for _, opts := range []*SplitOptions{
+		{Blocks: 1},
+		{Blocks: 2},
+		{Size: 4096},
+	} {
+		var out []byte
+		for _, part := range splitParts(t, compressed, opts) {
+			report, err := Verify(bytes.NewReader(part.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Streams != 1 {
+				t.Error("Each part should be a single stream. Got", report.Streams)
+			}
+			if opts.Blocks > 0 && len(report.Blocks) > opts.Blocks {
+				t.Error("Part has too many blocks. Got", len(report.Blocks))
+			}
+
+			data, err := ioutil.ReadAll(NewReader(part))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, data...)
+		}
+
+		if !bytes.Equal(out, expected) {
+			t.Error("Output of the parts is incorrect with options", *opts)
+		}
+	}
+}
+
+func TestSplitLevels(t *testing.T) {
+	compressed, _ := multistreamData(t)
+
+	// The streams have different block sizes, so their blocks can't share a part.
+	parts := splitParts(t, compressed, &SplitOptions{Blocks: 10})
+	if len(parts) != 2 {
+		t.Error("Incorrect number of parts. Got", len(parts), "wanted 2")
+	}
+}
+
+func TestSplitInvalidOptions(t *testing.T) {
+	err := Split(bytes.NewReader(nil), &SplitOptions{}, nil)
+	if err != errSplitOptions {
+		t.Error("Splitting without limits should fail. Got", err)
+	}
+}
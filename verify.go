@@ -0,0 +1,119 @@
+package bzip2
+
+import (
+	"io"
+
+	"github.com/larzconwell/bzip2/bits"
+)
+
+// BlockReport contains the details of a block checked by Verify.
+type BlockReport struct {
+	// Stream is the index of the stream the block is in.
+	Stream int
+	// Level is the compression level of the stream the block
+	// is in, which limits the size of the block.
+	Level int
+	// Offset is the bit offset of the block from the beginning
+	// of the data, pointing at the block magic.
+	Offset int64
+	// Size is the number of uncompressed bytes in the block.
+	Size int
+	// CRC is the blocks crc.
+	CRC uint32
+	// Err is the error found decoding the block, if any.
+	Err error
+}
+
+// VerifyReport contains the results of Verify.
+type VerifyReport struct {
+	// Streams is the number of streams found, streams
+	// are concatenated one after the other.
+	Streams int
+	// Level is the largest compression level in
+	// the stream headers.
+	Level int
+	// Blocks contains a report for each block found.
+	Blocks []BlockReport
+	// Failed is the index in Blocks of the block that
+	// failed to verify, or -1 if no block failed.
+	Failed int
+}
+
+// Verify reads bzip2 data from r decoding every block, verifying
+// the crc of each block and the combined crc of each stream. Any
+// concatenated streams are also verified.
+//
+// The report includes the blocks up to the first error, the
+// error is returned along with the report.
+func Verify(r io.Reader) (*VerifyReport, error) {
+	report := &VerifyReport{Failed: -1}
+	br := bits.NewReader(r)
+
+	for {
+		// The data can end after any stream but the first.
+		offset := br.Offset()
+		blockSize, err := readFileHeader(br)
+		if err == io.ErrUnexpectedEOF && report.Streams > 0 &&
+			br.Offset() == offset {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Streams++
+		if blockSize/baseBlockSize > report.Level {
+			report.Level = blockSize / baseBlockSize
+		}
+
+		err = verifyStream(br, blockSize, report)
+		if err != nil {
+			return report, err
+		}
+		br.Align()
+	}
+}
+
+// verifyStream verifies the blocks in a stream after its header,
+// adding them to the report.
+func verifyStream(br *bits.Reader, blockSize int, report *VerifyReport) error {
+	var crc uint32
+
+	for {
+		offset := br.Offset()
+		magic := br.ReadBits(magicLen)
+		if br.Err() != nil {
+			return bitsErr(br)
+		}
+
+		switch magic {
+		case blockMagic:
+			data, blockCRC, err := readBlock(br, blockSize)
+			report.Blocks = append(report.Blocks, BlockReport{
+				Stream: report.Streams - 1,
+				Level:  blockSize / baseBlockSize,
+				Offset: offset,
+				Size:   len(data),
+				CRC:    blockCRC,
+				Err:    err,
+			})
+			if err != nil {
+				report.Failed = len(report.Blocks) - 1
+				return err
+			}
+
+			crc = ((crc << 1) | (crc >> 31)) ^ blockCRC
+		case finalMagic:
+			streamCRC := uint32(br.ReadBits(32))
+			if br.Err() != nil {
+				return bitsErr(br)
+			}
+
+			if streamCRC != crc {
+				return ErrChecksum
+			}
+			return nil
+		default:
+			return StructuralError("bad magic value")
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package bzip2
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Streams != 1 || len(report.Blocks) != 3 || report.Failed != -1 {
+		t.Fatal("Report is incorrect. Got", report.Streams, len(report.Blocks), report.Failed)
+	}
+	if report.Blocks[0].Offset != 32 {
+		t.Error("First block offset incorrect. Got", report.Blocks[0].Offset, "wanted 32")
+	}
+
+	size := 0
+	for _, block := range report.Blocks {
+		size += block.Size
+	}
+	if size != len(linesData()) {
+		t.Error("Block sizes don't add up to the data size. Got", size)
+	}
+}
+
+func TestVerifyMultiStream(t *testing.T) {
+	var buf bytes.Buffer
+	for _, p := range []string{"banana", "apple"} {
+		writer := NewWriter(&buf)
+		_, err := writer.Write([]byte(p))
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := Verify(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Streams != 2 || len(report.Blocks) != 2 {
+		t.Fatal("Report is incorrect. Got", report.Streams, len(report.Blocks))
+	}
+	if report.Blocks[1].Stream != 1 || report.Blocks[1].Size != 5 {
+		t.Error("Second stream block is incorrect. Got", report.Blocks[1])
+	}
+}
+
+func TestVerifyCorruptBlock(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/lines.txt.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the second blocks crc, directly after its block magic.
+	offset := report.Blocks[1].Offset + magicLen
+	compressed[offset/8+1] ^= 0xff
+
+	report, err = Verify(bytes.NewReader(compressed))
+	if err != ErrChecksum {
+		t.Fatal("Corrupt block should return ErrChecksum. Got", err)
+	}
+
+	if report.Failed != 1 || report.Blocks[1].Err != ErrChecksum {
+		t.Error("Second block should have failed. Got", report.Failed)
+	}
+}
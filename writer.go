@@ -5,7 +5,8 @@ import (
 	"fmt"
 	"io"
 
-	"github.com/larzconwell/bzip2/internal/bits"
+	"github.com/larzconwell/bzip2/bits"
+	"github.com/larzconwell/bzip2/bwt"
 )
 
 const (
@@ -24,15 +25,89 @@ const (
 	BestCompression = flate.BestCompression
 )
 
+// ExtremeCompression is the level beyond BestCompression, using the
+// same block size but spending more time generating the Huffman
+// trees for each block to save a few more bytes.
+const ExtremeCompression = BestCompression + 1
+
+// WriterOptions are the options used to create a Writer
+// with NewWriterOptions.
+type WriterOptions struct {
+	// Level is the compression level, if zero the
+	// default level used by NewWriter is used.
+	Level int
+
+	// Concurrency is the number of blocks that can be compressed
+	// at the same time, with the compressed blocks written in
+	// order. If less than 2 blocks are compressed synchronously
+	// as they're filled.
+	Concurrency int
+
+	// StreamBlocks is the number of blocks written in each stream,
+	// after which a new stream is started so the next block begins
+	// on a byte boundary. The streams are concatenated, so the data
+	// is still valid for any decoder. If zero a single stream is
+	// written unless NewStream is called, or Rsyncable is set.
+	StreamBlocks int
+
+	// Checkpoint is called with a Checkpoint after each block is
+	// written and flushed to the underlying io.Writer, so writing
+	// can be resumed from it with NewWriterCheckpoint. When a block
+	// ends a stream it's called once the stream trailer is written.
+	// Any error returned stops the Writer.
+	Checkpoint func(Checkpoint) error
+
+	// Rsyncable ends blocks at points picked by a rolling hash of
+	// the input, like gzip --rsyncable, as well as when they're
+	// full. Inserting or removing data then only changes the blocks
+	// around it, the rest compressing to the same bits as before.
+	// Blocks have at least a quarter of the level's block size, so
+	// the output is slightly larger. If StreamBlocks is zero each
+	// block is written in its own stream, keeping the blocks byte
+	// aligned so the bytes are identical too.
+	Rsyncable bool
+
+	// AdaptiveBlocks ends blocks early when the distribution of the
+	// input changes, like text followed by binary data, so each block
+	// gets Huffman tables suited to its data. The input is buffered
+	// a window at a time, and a block with at least a few windows
+	// ends before a window whose byte frequencies differ too much
+	// from the block's, if compressing the end of the block and the
+	// window separately takes fewer bits than together. Smaller
+	// blocks give the BWT less context, so it suits data where the
+	// different kinds don't repeat. Checking the windows that differ
+	// makes writing slower.
+	AdaptiveBlocks bool
+
+	// Sorter performs the Burrows-Wheeler Transform for each block,
+	// if nil bwt.SuffixArray is used. Blocks compressed concurrently
+	// use it at the same time.
+	Sorter bwt.Sorter
+}
+
 // Writer is an io.WriteCloser. Writes to a Writer are
 // compressed and written to an underlying io.Writer.
 type Writer struct {
-	bw          *bits.Writer
-	block       *block
-	crc         uint32
-	wroteHeader bool
-	closed      bool
-	err         error
+	bw            *bits.Writer
+	base          int64
+	block         *block
+	crc           uint32
+	concurrency   int
+	pending       []chan *compressedBlock
+	index         Index
+	wroteHeader   bool
+	streamEnded   bool
+	streamBlocks  int
+	blockLimit    int
+	streamOffsets []int64
+	input         int64
+	writtenBlocks int
+	checkpointFn  func(Checkpoint) error
+	chunker       *chunker
+	window        []byte
+	continued     bool
+	closed        bool
+	err           error
 }
 
 // NewWriter returns a new Writer. Writes to the returned
@@ -44,7 +119,7 @@ type Writer struct {
 func NewWriter(w io.Writer) *Writer {
 	return &Writer{
 		bw:    bits.NewWriter(w),
-		block: newBlock(6 * baseBlockSize),
+		block: newBlock(6*baseBlockSize, false, bwt.SuffixArray{}),
 	}
 }
 
@@ -52,19 +127,59 @@ func NewWriter(w io.Writer) *Writer {
 // compression level.
 //
 // The levels range from 1 (BestSpeed) to 9 (BestCompression);
-// higher levels typically run slower but compress more.
+// higher levels typically run slower but compress more. Level
+// 10 (ExtremeCompression) is even slower than level 9.
 //
-// If level is in the range [1, 9] then the error returned will
+// If level is in the range [1, 10] then the error returned will
 // be nil. Otherwise the error returned will be non-nil.
 func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
-	if level < BestSpeed || level > BestCompression {
+	return NewWriterOptions(w, &WriterOptions{Level: level})
+}
+
+// NewWriterOptions is like NewWriter but uses the options given
+// to configure the Writer.
+//
+// If the options are valid then the error returned will be nil.
+// Otherwise the error returned will be non-nil.
+func NewWriterOptions(w io.Writer, opts *WriterOptions) (*Writer, error) {
+	level := opts.Level
+	if level == 0 {
+		level = 6
+	}
+	if level < BestSpeed || level > ExtremeCompression {
 		return nil, fmt.Errorf("bzip2: invalid compression level: %d", level)
 	}
 
-	return &Writer{
-		bw:    bits.NewWriter(w),
-		block: newBlock(level * baseBlockSize),
-	}, nil
+	extreme := level == ExtremeCompression
+	if extreme {
+		level = BestCompression
+	}
+	sorter := opts.Sorter
+	if sorter == nil {
+		sorter = bwt.SuffixArray{}
+	}
+
+	writer := &Writer{
+		bw:           bits.NewWriter(w),
+		block:        newBlock(level*baseBlockSize, extreme, sorter),
+		concurrency:  opts.Concurrency,
+		blockLimit:   opts.StreamBlocks,
+		checkpointFn: opts.Checkpoint,
+	}
+	if opts.Rsyncable {
+		writer.chunker = newChunker(writer.block.size)
+
+		// Blocks after the first don't begin on a byte boundary
+		// unless they begin a stream.
+		if writer.blockLimit == 0 {
+			writer.blockLimit = 1
+		}
+	}
+	if opts.AdaptiveBlocks {
+		writer.window = make([]byte, 0, statsWindow)
+	}
+
+	return writer, nil
 }
 
 // Write writes a compressed form of p to the underlying
@@ -74,6 +189,9 @@ func (w *Writer) Write(p []byte) (int, error) {
 	if w.err != nil {
 		return 0, w.err
 	}
+	if len(p) == 0 {
+		return 0, nil
+	}
 	var n int
 
 	// Handle writing the file header.
@@ -90,8 +208,11 @@ func (w *Writer) Write(p []byte) (int, error) {
 	return n, w.err
 }
 
-// writeHeader writes the file header.
+// writeHeader writes the file header, recording
+// the byte offset the stream begins at.
 func (w *Writer) writeHeader() error {
+	w.streamOffsets = append(w.streamOffsets, w.offset()/8)
+	w.streamEnded = false
 	w.bw.WriteBits(16, fileMagic)
 	w.bw.WriteBits(8, 'h')
 	w.bw.WriteBits(8, uint64('0'+w.block.size/baseBlockSize))
@@ -99,40 +220,149 @@ func (w *Writer) writeHeader() error {
 	return w.bw.Err()
 }
 
+// offset gets the bit offset in the output
+// the next value is written at.
+func (w *Writer) offset() int64 {
+	return w.base + w.bw.Offset()
+}
+
+// level gets the compression level used, which is
+// only the block size unless using ExtremeCompression.
+func (w *Writer) level() int {
+	if w.block.extreme {
+		return ExtremeCompression
+	}
+
+	return w.block.size / baseBlockSize
+}
+
 // write handles the writing of block data and writing
 // completed blocks to the underlying io.Writer.
 func (w *Writer) write(p []byte) (int, error) {
-	n, err := w.block.Write(p)
-	if err != errBlockSizeReached {
+	if w.window != nil {
+		return w.bufferWindow(p)
+	}
+
+	var n int
+	for n < len(p) {
+		nn, err := w.fill(p[n:])
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// fill writes as much of p as fits in the current block, writing
+// the complete block once it ends. The number of bytes written to
+// the block is returned, left over bytes belong to the next block.
+func (w *Writer) fill(p []byte) (int, error) {
+	data := p
+	boundary := false
+	if w.chunker != nil {
+		end := w.chunker.boundary(w.block, p)
+		if end >= 0 {
+			data = p[:end]
+			boundary = true
+		}
+	}
+
+	n, err := w.block.Write(data)
+	if w.window != nil {
+		w.block.count(data[:n])
+	}
+	if err != errBlockSizeReached && !boundary {
 		return n, err
 	}
 
-	// Write the complete block, left over
-	// bytes being written to a new block.
-	err = w.writeBlock()
+	return n, w.writeBlock()
+}
+
+// writeBlock writes the current block to the underlying
+// io.Writer and updates the files crc. If the stream has
+// reached its block limit the stream is ended.
+func (w *Writer) writeBlock() error {
+	// Handle writing the file header when the previous block ended the stream.
+	if !w.wroteHeader {
+		err := w.writeHeader()
+		if err != nil {
+			return err
+		}
+
+		w.wroteHeader = true
+	}
+
+	var err error
+	if w.concurrency > 1 {
+		err = w.queueBlock()
+	} else {
+		w.index.add(w.offset(), int64(w.block.dataLen), w.block.crc)
+		err = w.block.WriteBlock(w.bw)
+		if err == nil {
+			err = w.blockWritten(w.block.dataLen, w.block.crc)
+			w.block = newBlock(w.block.size, w.block.extreme, w.block.sorter)
+		}
+	}
 	if err != nil {
-		return n, err
+		return err
 	}
 
-	if n != len(p) {
-		var nn int
-		nn, err = w.write(p[n:])
-		n += nn
+	w.streamBlocks++
+	if w.streamBlocks == w.blockLimit {
+		return w.endStream()
+	}
+	return nil
+}
+
+// queueBlock compresses the current block in the background.
+// If the max number of blocks are being compressed the oldest
+// is waited on and written first.
+func (w *Writer) queueBlock() error {
+	if len(w.pending) == w.concurrency {
+		err := w.writePending()
+		if err != nil {
+			return err
+		}
 	}
 
-	return n, err
+	block := w.block
+	compressed := make(chan *compressedBlock, 1)
+	go func() {
+		compressed <- block.Compress()
+	}()
+	w.pending = append(w.pending, compressed)
+
+	w.block = newBlock(w.block.size, w.block.extreme, w.block.sorter)
+	return nil
 }
 
-// writeBlock writes the current block to the
-// underlying io.Writer and updates the files crc.
-func (w *Writer) writeBlock() error {
-	err := w.block.WriteBlock(w.bw)
+// writePending waits for the oldest block being compressed,
+// writes it to the underlying io.Writer and updates the files crc.
+func (w *Writer) writePending() error {
+	compressed := <-w.pending[0]
+	w.pending[0] = nil
+	w.pending = w.pending[1:]
+
+	w.index.add(w.offset(), int64(compressed.dataLen), compressed.crc)
+	err := compressed.WriteBlock(w.bw)
 	if err != nil {
 		return err
 	}
 
-	w.crc = ((w.crc << 1) | (w.crc >> 31)) ^ w.block.crc
-	w.block = newBlock(w.block.size)
+	return w.blockWritten(compressed.dataLen, compressed.crc)
+}
+
+// flushPending writes all the blocks being compressed.
+func (w *Writer) flushPending() error {
+	for len(w.pending) > 0 {
+		err := w.writePending()
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -142,9 +372,20 @@ func (w *Writer) Flush() error {
 	if w.err != nil {
 		return w.err
 	}
-	if w.closed || w.block.Len() == 0 {
+	if w.closed {
 		return nil
 	}
+	w.err = w.flushWindow()
+	if w.err != nil {
+		return w.err
+	}
+	if w.block.Len() == 0 {
+		w.err = w.flushPending()
+		if w.err == nil {
+			w.err = w.bw.Flush()
+		}
+		return w.err
+	}
 
 	// Handle writing the file header.
 	if !w.wroteHeader {
@@ -157,6 +398,12 @@ func (w *Writer) Flush() error {
 	}
 
 	w.err = w.writeBlock()
+	if w.err == nil {
+		w.err = w.flushPending()
+	}
+	if w.err == nil {
+		w.err = w.bw.Flush()
+	}
 	return w.err
 }
 
@@ -165,13 +412,73 @@ func (w *Writer) Flush() error {
 // to dst instead.
 func (w *Writer) Reset(dst io.Writer) {
 	w.bw = bits.NewWriter(dst)
-	w.block = newBlock(w.block.size)
+	w.base = 0
+	w.block = newBlock(w.block.size, w.block.extreme, w.block.sorter)
 	w.crc = 0
+	w.pending = nil
+	if w.window != nil {
+		w.window = w.window[:0]
+	}
+	w.index = Index{}
 	w.wroteHeader = false
+	w.streamEnded = false
+	w.streamBlocks = 0
+	w.streamOffsets = nil
+	w.input = 0
+	w.writtenBlocks = 0
+	w.continued = false
 	w.closed = false
 	w.err = nil
 }
 
+// Index gets an index of the blocks written, which is complete
+// once the Writer is closed. Blocks are only included once
+// they've been written to the bit writer. If the Writer continues
+// existing data, like from NewAppendWriter or NewWriterCheckpoint,
+// nil is returned.
+func (w *Writer) Index() *Index {
+	if w.continued {
+		return nil
+	}
+
+	blocks := make([]IndexEntry, len(w.index.Blocks))
+	copy(blocks, w.index.Blocks)
+
+	return &Index{Level: w.block.size / baseBlockSize, Blocks: blocks}
+}
+
+// StreamOffsets gets the byte offset of each stream written,
+// pointing at the streams header. It's complete once the Writer
+// is closed. Each stream is a valid bzip2 file on its own, so with
+// WriterOptions.StreamBlocks the data can be split at the offsets.
+func (w *Writer) StreamOffsets() []int64 {
+	offsets := make([]int64, len(w.streamOffsets))
+	copy(offsets, w.streamOffsets)
+
+	return offsets
+}
+
+// NewStream ends the current stream, writing any unwritten data and
+// the stream trailer to the underlying io.Writer. Writes after it
+// begin a new stream with its own header and crc, the streams being
+// concatenated like the output of pbzip2. If nothing has been
+// written since the last stream ended no stream is started.
+func (w *Writer) NewStream() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.flushWindow()
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed || (!w.wroteHeader && w.block.Len() == 0) {
+		return nil
+	}
+
+	w.err = w.endStream()
+	return w.err
+}
+
 // Close closes the Writer, flushing any unwritten data to the
 // underlying io.Writer, but does not close the underlying io.Writer.
 func (w *Writer) Close() error {
@@ -181,8 +488,17 @@ func (w *Writer) Close() error {
 	if w.closed {
 		return nil
 	}
+	w.err = w.flushWindow()
+	if w.err != nil {
+		return w.err
+	}
 	w.closed = true
 
+	// A stream has already ended and nothing has been written since.
+	if w.streamEnded && w.block.Len() == 0 {
+		return nil
+	}
+
 	// Handle writing the file header.
 	if !w.wroteHeader {
 		w.err = w.writeHeader()
@@ -193,21 +509,38 @@ func (w *Writer) Close() error {
 		w.wroteHeader = true
 	}
 
-	// Flush the current block.
+	w.err = w.endStream()
+	return w.err
+}
+
+// endStream writes the current block and any blocks being
+// compressed, then writes the stream trailer so the next
+// block begins a new stream.
+func (w *Writer) endStream() error {
 	if w.block.Len() != 0 {
-		w.err = w.writeBlock()
-		if w.err != nil {
-			return w.err
+		err := w.writeBlock()
+
+		// The block may have reached the block limit, ending the stream.
+		if err != nil || !w.wroteHeader {
+			return err
 		}
 	}
+	err := w.flushPending()
+	if err != nil {
+		return err
+	}
 
 	w.bw.WriteBits(48, finalMagic)
 	w.bw.WriteBits(32, uint64(w.crc))
-	bufferedBits := w.bw.Buffered()
-	if bufferedBits != 0 {
-		w.bw.WriteBits(8-bufferedBits, 0)
+	w.crc = 0
+	w.wroteHeader = false
+	w.streamEnded = true
+	w.streamBlocks = 0
+	w.writtenBlocks = 0
+	err = w.bw.Close()
+	if err != nil || w.closed {
+		return err
 	}
 
-	w.err = w.bw.Err()
-	return w.err
+	return w.checkpoint()
 }
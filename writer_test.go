@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/larzconwell/bzip2/bwt"
 	"github.com/larzconwell/bzip2/internal/testhelpers"
 )
 
@@ -128,3 +129,297 @@ func TestMultiBlock(t *testing.T) {
 		t.Error("Output is incorrect.")
 	}
 }
+
+func TestConcurrentMatchesSequential(t *testing.T) {
+	var expected bytes.Buffer
+	var actual bytes.Buffer
+	data := testhelpers.RandomRunData(2*baseBlockSize + baseBlockSize/2)
+
+	writer, _ := NewWriterLevel(&expected, 1)
+	_, err := writer.Write(data)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err = NewWriterOptions(&actual, &WriterOptions{Level: 1, Concurrency: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write(data)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+		t.Error("Concurrent output doesn't match sequential output")
+	}
+}
+
+func TestConcurrentFlush(t *testing.T) {
+	var expected bytes.Buffer
+	var actual bytes.Buffer
+
+	for _, concurrency := range []int{0, 2} {
+		buf := &expected
+		if concurrency > 0 {
+			buf = &actual
+		}
+
+		writer, err := NewWriterOptions(buf, &WriterOptions{Concurrency: concurrency})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []string{"banana", "apple", "orange"} {
+			_, err = writer.Write([]byte(p))
+			if err == nil {
+				err = writer.Flush()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = writer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+		t.Error("Concurrent output doesn't match sequential output")
+	}
+}
+
+func TestNewWriterOptionsInvalidLevel(t *testing.T) {
+	_, err := NewWriterOptions(ioutil.Discard, &WriterOptions{Level: ExtremeCompression + 1})
+	if err == nil {
+		t.Error("Invalid level should return an error")
+	}
+}
+
+func TestWriterNewStream(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+
+	for _, p := range []string{"banana", "apple"} {
+		_, err := writer.Write([]byte(p))
+		if err == nil {
+			err = writer.NewStream()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Streams != 2 {
+		t.Error("Incorrect number of streams. Got", report.Streams, "wanted 2")
+	}
+
+	out, err := ioutil.ReadAll(bzip2.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "bananaapple" {
+		t.Error("Output is incorrect. Got", string(out), "wanted bananaapple")
+	}
+}
+
+func TestWriterStreamBlocks(t *testing.T) {
+	expected := linesData()
+
+	for _, opts := range []WriterOptions{
+		{Level: BestSpeed, StreamBlocks: 1},
+		{Level: BestSpeed, StreamBlocks: 2, Concurrency: 4},
+	} {
+		var buf bytes.Buffer
+		writer, err := NewWriterOptions(&buf, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = writer.Write(expected)
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The data is 3 blocks at the best speed.
+		offsets := writer.StreamOffsets()
+		streams := (3 + opts.StreamBlocks - 1) / opts.StreamBlocks
+		if len(offsets) != streams || offsets[0] != 0 {
+			t.Fatal("Stream offsets incorrect. Got", offsets)
+		}
+
+		// Each stream is a valid file on its own.
+		var out []byte
+		compressed := buf.Bytes()
+		for i, offset := range offsets {
+			end := len(compressed)
+			if i+1 < len(offsets) {
+				end = int(offsets[i+1])
+			}
+
+			data, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(compressed[offset:end])))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, data...)
+		}
+
+		if !bytes.Equal(out, expected) {
+			t.Error("Output of the streams is incorrect with", opts.StreamBlocks,
+				"blocks per stream")
+		}
+	}
+}
+
+func TestWriterStreamBlocksFilled(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriterOptions(&buf, &WriterOptions{Level: BestSpeed, StreamBlocks: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Filling the last block ends the last stream, so Close has nothing to write.
+	_, err = writer.Write(testhelpers.NoRunData(2 * baseBlockSize))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Streams != 2 || len(writer.StreamOffsets()) != 2 {
+		t.Error("Incorrect number of streams. Got", report.Streams,
+			len(writer.StreamOffsets()), "wanted 2")
+	}
+}
+
+func TestWriterExtremeCompression(t *testing.T) {
+	data := linesData()
+
+	var best, extreme bytes.Buffer
+	for level, buf := range map[int]*bytes.Buffer{BestCompression: &best, ExtremeCompression: &extreme} {
+		writer, err := NewWriterLevel(buf, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = writer.Write(data)
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if extreme.Len() > best.Len() {
+		t.Error("Extreme compression shouldn't be larger. Got", extreme.Len(),
+			"bytes wanted at most", best.Len())
+	}
+	if extreme.Bytes()[3] != '9' {
+		t.Error("Extreme compression should use the largest block size. Got", extreme.Bytes()[3])
+	}
+
+	out, err := ioutil.ReadAll(bzip2.NewReader(&extreme))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Error("Output of extreme compression is incorrect")
+	}
+}
+
+// countingSorter is a bwt.Sorter counting the blocks transformed.
+type countingSorter struct {
+	bwt.RotationSort
+	blocks int
+}
+
+func (cs *countingSorter) Transform(dst, src []byte) int {
+	cs.blocks++
+	return cs.RotationSort.Transform(dst, src)
+}
+
+func TestWriterSorter(t *testing.T) {
+	data := linesData()
+
+	var expected bytes.Buffer
+	writer, err := NewWriterLevel(&expected, BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write(data)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sorter := &countingSorter{}
+	var actual bytes.Buffer
+	writer, err = NewWriterOptions(&actual, &WriterOptions{Level: BestSpeed, Sorter: sorter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = writer.Write(data)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sorter.blocks != len(writer.Index().Blocks) {
+		t.Error("Sorter should transform each block. Got", sorter.blocks,
+			"wanted", len(writer.Index().Blocks))
+	}
+	if !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+		t.Error("Output with the sorter doesn't match the default output")
+	}
+}
+
+func BenchmarkWriterSorter(b *testing.B) {
+	data := linesData()
+
+	for name, sorter := range map[string]bwt.Sorter{
+		"SuffixArray":  bwt.SuffixArray{},
+		"RotationSort": bwt.RotationSort{},
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				writer, err := NewWriterOptions(ioutil.Discard, &WriterOptions{Sorter: sorter})
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, err = writer.Write(data)
+				if err == nil {
+					err = writer.Close()
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}